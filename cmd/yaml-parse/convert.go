@@ -0,0 +1,81 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benedict-pureweb/go-utils/yamlutils"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func convertCommand(args []string) int {
+	var from, to string
+	opt := getoptions.New()
+	opt.Self("convert", `Converts a document between the YAML, JSON, TOML, Java properties and dotenv formats.
+    Reads from STDIN when no file is given or the file is '-' after '--'.
+    Properties and dotenv values are read as strings, nested keys are joined with '.' and '_' when writing them.
+
+    Usage: yaml-parse convert [<options>] [<file>]`)
+	opt.Bool("help", false, opt.Alias("?"))
+	opt.Bool("n", false, opt.Description("Remove trailing spaces."))
+	opt.StringVar(&from, "from", "", opt.ArgName("yaml|json|toml|properties|dotenv"),
+		opt.Description("Input format, detected from the file extension by default, YAML otherwise."))
+	opt.StringVar(&to, "to", "yaml", opt.ArgName("yaml|json|toml|properties|dotenv"),
+		opt.Description("Output format."))
+	files, err := opt.Parse(args)
+	if opt.Called("help") {
+		fmt.Fprintln(os.Stderr, opt.Help())
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: too many files: %s\n", strings.Join(files, ", "))
+		return 1
+	}
+	file := "-"
+	if len(files) == 1 {
+		file = files[0]
+	}
+	outFormat, err := yamlutils.ParseFileFormat(to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	inFormat := yamlutils.FileFormatFromName(file)
+	if opt.Called("from") {
+		inFormat, err = yamlutils.ParseFileFormat(from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			return 1
+		}
+	}
+	yml, err := readYMLWithFormat(file, inFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: reading %s file '%s': %s\n", inFormat, file, err)
+		return 1
+	}
+	out, err := yml.Encode(outFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: converting to %s: %s\n", outFormat, err)
+		return 1
+	}
+	str := string(out)
+	if opt.Called("n") {
+		str = strings.TrimSpace(str)
+	}
+	fmt.Print(str)
+	return 0
+}
@@ -0,0 +1,92 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/benedict-pureweb/go-utils/yamlutils"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func diffCommand(args []string) int {
+	var output string
+	opt := getoptions.New()
+	opt.Self("diff", `Reports the structural differences between two YAML files.
+    Key order and formatting differences are ignored.
+    Use '-' after '--' to read a file from STDIN, for example: 'yaml-parse diff -- a.yml -'.
+
+    Exit status is 0 when the files are equal, 1 when they differ and 2 on errors.
+
+    Usage: yaml-parse diff [<options>] <a.yml> <b.yml>`)
+	opt.Bool("help", false, opt.Alias("?"))
+	opt.Bool("quiet", false, opt.Alias("q"), opt.Description("Only report differences through the exit status."))
+	opt.StringVar(&output, "output", "human", opt.Alias("o"), opt.ArgName("human|unified|json|json-pretty"),
+		opt.Description(`Output format.
+human prints one line per added (+), removed (-) or changed (~) path.
+unified prints a unified diff of both files with sorted keys.
+json prints a list of changes with their type, path, from and to values in a single line, json-pretty indents it.`))
+	files, err := opt.Parse(args)
+	if opt.Called("help") {
+		fmt.Fprintln(os.Stderr, opt.Help())
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 2
+	}
+	if len(files) != 2 {
+		fmt.Fprintf(os.Stderr, "ERROR: diff requires two files\n")
+		return 2
+	}
+	format, err := yamlutils.ParseOutputFormat(output)
+	if output != "human" && output != "unified" && (err != nil || format == yamlutils.FormatYAML) {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %s\n", yamlutils.ErrInvalidFormat, output)
+		return 2
+	}
+	a, err := readYML(files[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: reading yaml file '%s': %s\n", files[0], err)
+		return 2
+	}
+	b, err := readYML(files[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: reading yaml file '%s': %s\n", files[1], err)
+		return 2
+	}
+
+	changes := yamlutils.Diff(a, b)
+	logger.Printf("changes: %d\n", len(changes))
+	if opt.Called("quiet") {
+		if len(changes) > 0 {
+			return 1
+		}
+		return 0
+	}
+	var str string
+	switch output {
+	case "human":
+		str = yamlutils.DiffString(changes)
+	case "unified":
+		str, err = yamlutils.UnifiedDiff(files[0], files[1], a, b)
+	default:
+		str, err = yamlutils.DiffJSON(changes, format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 2
+	}
+	fmt.Print(str)
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -8,13 +8,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"strings"
 
-	"github.com/DavidGamba/go-utils/yamlutils"
+	"github.com/benedict-pureweb/go-utils/yamlutils"
 
 	"github.com/DavidGamba/go-getoptions"
 )
@@ -29,12 +30,33 @@ var logger = log.New(ioutil.Discard, "", log.LstdFlags)
 func main() {
 	var file string
 	var include bool
-	var add string
+	var add, set string
 	var keys []string
+	var docIndex int
+	var output string
+	var backupSuffix string
+	var from string
+	var separator string
+	var exportPrefix, shell string
+	var queries []string
+	var queryFormat string
+	var expr string
 	opt := getoptions.New()
 	opt.Self("", `Parses YAML input passed from file or piped to STDIN and filters it by key or index.
 
+    Commands:
+
+        convert    Convert between YAML, JSON, TOML, properties and dotenv files.
+        diff       Report the differences between two YAML files.
+        merge      Deep merge YAML files.
+        patch      Apply a JSON Patch or JSON Merge Patch to a YAML file.
+        unflatten  Build a YAML document from 'path=value' lines.
+        validate   Validate YAML files against a JSON Schema.
+
+    Use 'yaml-parse <command> --help' for extra details.
+
     Source: https://github.com/benedict-pureweb/go-utils`)
+	opt.SetRequireOrder()
 	opt.Bool("help", false, opt.Alias("?"))
 	opt.Bool("debug", false)
 	opt.Bool("version", false, opt.Alias("V"))
@@ -42,12 +64,72 @@ func main() {
 	opt.Bool("silent", false, opt.Description("Don't print full context errors."))
 	opt.BoolVar(&include, "include", false, opt.Description("Include parent key if it is a map key."))
 	opt.StringVar(&file, "file", "", opt.Alias("f"), opt.ArgName("file"), opt.Description("YAML file to read."))
+	opt.StringVar(&from, "from", "", opt.ArgName("yaml|json|toml|properties|dotenv"),
+		opt.Description(`Input format, detected from the --file extension by default, YAML otherwise.
+--doc, --all-docs, --follow-includes and --in-place require YAML or JSON input.`))
 	opt.StringVar(&add, "add", "", opt.ArgName("yaml/json input"), opt.Description("Child input to add at the current location."))
+	opt.StringVar(&set, "set", "", opt.ArgName("yaml/json input"), opt.Description("Replace the element at the current location with the given input."))
+	opt.Bool("create", false, opt.Description("Create missing parent keys when using --set."))
+	opt.Bool("delete", false, opt.Description("Delete the map key or list item at the current location."))
+	opt.Bool("keys", false, opt.Description("Print the keys of the map or the indexes of the list at the current location."))
+	opt.Bool("length", false, opt.Description("Print the number of elements of the map or list, or the characters of the string at the current location."))
+	opt.Bool("type", false, opt.Description("Print the type of the element at the current location: map, list, string, int, float, bool or null."))
+	opt.Bool("paths", false, opt.Description("Print the concrete path of each element matched by the key together with its value."))
+	opt.Bool("flatten", false, opt.Description(`Print every leaf of the element at the current location as a 'path=value' line.
+Paths can be used with --key and 'yaml-parse unflatten' rebuilds the document.`))
+	opt.StringVar(&separator, "separator", yamlutils.DefaultFlattenSeparator, opt.ArgName("separator"),
+		opt.Description("Separator between the path and the value when using --flatten."))
+	opt.Bool("quote", false, opt.Description(`Write --flatten values as JSON, strings are quoted and escaped.
+Required when the element has multi-line strings.`))
+	opt.StringVar(&exportPrefix, "export", "", opt.ArgName("prefix"),
+		opt.Description(`Print shell assignments for every value of the element at the current location, for example: PREFIX_DB_HOST='localhost'.
+Names are the prefix and the path joined with '_' in upper case, lists of values are arrays.
+Use with eval, for example: eval "$(yaml-parse -f config.yml --export APP)".`))
+	opt.StringVar(&shell, "shell", "bash", opt.ArgName("bash|sh|fish"),
+		opt.Description("Shell syntax used by --export, sh has no arrays so lists of values are an error."))
+	opt.Bool("preserve", false, opt.Description("Preserve comments, key order and formatting of the input when editing."))
+	opt.Bool("in-place", false, opt.Alias("i"), opt.Description(`Write the result of --add, --set or --delete back to --file instead of STDOUT.
+The file is replaced atomically and keeps its file mode and format, --output only applies to STDOUT.`))
+	opt.StringVar(&backupSuffix, "backup", "", opt.ArgName("suffix"),
+		opt.Description("Keep a backup of the original file with the given suffix when using --in-place, for example '.bak'."))
+	opt.StringVar(&output, "output", "yaml", opt.Alias("o"), opt.ArgName("yaml|json|json-pretty"),
+		opt.Description("Output format, json is compact JSON in a single line and json-pretty is indented JSON."))
+	opt.Bool("resolve-anchors", false, opt.Description(`Print the document with anchors, aliases and '<<' merge keys resolved.
+Requires --preserve, without it the output is always resolved.`))
+	opt.Bool("anchor", false, opt.Description("Print the name of the anchor the element at the current location came from."))
+	opt.Bool("follow-includes", false, opt.Description(`Replace elements tagged with '!include <path>' with the contents of the file.
+Paths are relative to the including file and glob patterns include a list with every matching file.
+Can't be used with --in-place.`))
+	opt.Bool("expand", false, opt.Description(`Expand placeholders in string values before running the operation:
+'${VAR}' and '${VAR:-default}' environment variables,
+'${file:/path}' file contents and '${ref:path/to/key}' references to other elements of the document.
+Use '$${' for a literal '${'. Can't be used with --in-place.`))
+	opt.IntVar(&docIndex, "doc", 0, opt.ArgName("index"), opt.Description("Document to use from a multi-document stream."))
+	opt.Bool("all-docs", false, opt.Description("Apply the operation to every document of a multi-document stream."))
 	opt.StringSliceVar(&keys, "key", 1, 99, opt.Alias("k"), opt.ArgName("key/index"),
 		opt.Description(`Key or index to descend to.
 Multiple keys allow to descend further.
-Indexes are positive integers.`))
-	_, err := opt.Parse(os.Args[1:])
+Indexes are integers, negative indexes count from the end of the list.
+Slices with the form 'start:end' select a range of list items.
+List items can be selected with a filter, for example: '[name=api]'.
+Filters support the =, !=, <, <=, > and >= operators.
+Escape '/' in keys with '\/' or quote the key, for example: 'labels/"app.kubernetes.io/name"'.
+Use '*' to match every map value or list item and '**' to descend recursively.
+Escaped and quoted keys, for example '\*' or '"1:3"', only match map keys with that name.`))
+	opt.StringSliceVar(&queries, "query", 1, 99, opt.Alias("q"), opt.ArgName("path"),
+		opt.Description(`Path of an independent query, use it multiple times to get several values from a single read.
+Queries use the same syntax and return the same values as --key, a failing query prints an error and an empty value
+without stopping the rest.`))
+	opt.StringVar(&queryFormat, "query-format", "lines", opt.ArgName("lines|tsv|json"),
+		opt.Description(`Output format of --query results:
+lines prints one value per line, tsv prints a tab separated row and json prints an object with the queries as keys.
+With --output yaml, lines and tsv print maps and lists in flow style, with the JSON outputs every value is JSON.`))
+	opt.StringVar(&expr, "expression", "", opt.Alias("e"), opt.ArgName("expression"),
+		opt.Description(`jq-like expression evaluated against the document, for example:
+'.servers[] | select(.port > 1024) | "\(.name):\(.port)"'.
+Supports pipes, select(), map(), keys, length, has(), comparisons, and, or and string interpolation.
+Each output is printed in its own line or YAML document.`))
+	remaining, err := opt.Parse(os.Args[1:])
 	if opt.Called("help") {
 		fmt.Fprintln(os.Stderr, opt.Help())
 		os.Exit(1)
@@ -64,64 +146,301 @@ Indexes are positive integers.`))
 		logger.SetOutput(os.Stderr)
 		yamlutils.Logger.SetOutput(os.Stderr)
 	}
+	if len(remaining) > 0 {
+		switch remaining[0] {
+		case "convert":
+			os.Exit(convertCommand(remaining[1:]))
+		case "diff":
+			os.Exit(diffCommand(remaining[1:]))
+		case "merge":
+			os.Exit(mergeCommand(remaining[1:]))
+		case "patch":
+			os.Exit(patchCommand(remaining[1:]))
+		case "unflatten":
+			os.Exit(unflattenCommand(remaining[1:]))
+		case "validate":
+			os.Exit(validateCommand(remaining[1:]))
+		default:
+			fmt.Fprintf(os.Stderr, "ERROR: unknown command '%s'\n", remaining[0])
+			os.Exit(1)
+		}
+	}
 	var xpath []string
 	for _, k := range keys {
-		xpath = append(xpath, strings.Split(k, "/")...)
+		xpath = append(xpath, yamlutils.SplitPath(k)...)
 	}
 	logger.Printf("path: '%s'\n", strings.Join(xpath, ","))
+	format, err := yamlutils.ParseOutputFormat(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	qFormat, err := yamlutils.ParseQueryFormat(queryFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	// Only one mode is run, reject the extra ones instead of ignoring them
+	modes := []string{}
+	for _, mode := range []string{"query", "expression", "add", "set", "delete", "anchor", "export", "flatten",
+		"keys", "length", "type", "paths"} {
+		if opt.Called(mode) {
+			modes = append(modes, "--"+mode)
+		}
+	}
+	if len(modes) > 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: %s can't be used together\n", strings.Join(modes, ", "))
+		os.Exit(1)
+	}
+	if opt.Called("query") && (opt.Called("key") || opt.Called("in-place")) {
+		fmt.Fprintf(os.Stderr, "ERROR: --query can't be used with --key or --in-place\n")
+		os.Exit(1)
+	}
+	if opt.Called("expression") {
+		if opt.Called("key") || opt.Called("query") || opt.Called("in-place") {
+			fmt.Fprintf(os.Stderr, "ERROR: --expression can't be used with --key, --query or --in-place\n")
+			os.Exit(1)
+		}
+		_, err = yamlutils.CompileExpr(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	exportShell, err := yamlutils.ParseShell(shell)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
 
 	// Check if stdin is pipe p or device D
 	statStdin, _ := os.Stdin.Stat()
 	stdinIsDevice := (statStdin.Mode() & os.ModeDevice) != 0
 
-	var yml *yamlutils.YML
-	if !stdinIsDevice && !opt.Called("file") {
-		logger.Printf("Reading from stdin\n")
-		reader := os.Stdin
-		yml, err = yamlutils.NewFromReader(reader)
+	readStream := opt.Called("doc") || opt.Called("all-docs")
+	readStdin := !stdinIsDevice && !opt.Called("file")
+	if !readStdin && !opt.Called("file") {
+		fmt.Fprintf(os.Stderr, "ERROR: missing argument '--file <file>'\n")
+		os.Exit(1)
+	}
+	inFormat := yamlutils.FileFormatFromName(file)
+	if opt.Called("from") {
+		inFormat, err = yamlutils.ParseFileFormat(from)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: reading yaml from STDIN: %s\n", err)
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 			os.Exit(1)
 		}
-	} else {
-		logger.Printf("Reading from file: %s\n", file)
+	}
+	// JSON is read by the YAML readers
+	if inFormat != yamlutils.FileYAML && inFormat != yamlutils.FileJSON &&
+		(readStream || opt.Called("follow-includes") || opt.Called("in-place")) {
+		fmt.Fprintf(os.Stderr, "ERROR: --doc, --all-docs, --follow-includes and --in-place require YAML or JSON input\n")
+		os.Exit(1)
+	}
+	if opt.Called("in-place") {
 		if !opt.Called("file") {
-			fmt.Fprintf(os.Stderr, "ERROR: missing argument '--file <file>'\n")
+			fmt.Fprintf(os.Stderr, "ERROR: --in-place requires '--file <file>'\n")
+			os.Exit(1)
+		}
+		if !opt.Called("add") && !opt.Called("set") && !opt.Called("delete") {
+			fmt.Fprintf(os.Stderr, "ERROR: --in-place requires --add, --set or --delete\n")
+			os.Exit(1)
+		}
+		// The expanded values, like secrets read from files or the environment, must not be saved
+		if opt.Called("expand") {
+			fmt.Fprintf(os.Stderr, "ERROR: --expand can't be used with --in-place\n")
 			os.Exit(1)
 		}
-		yml, err = yamlutils.NewFromFile(file)
+	}
+
+	// Anchors and aliases are only kept with --preserve, otherwise they are always resolved
+	if opt.Called("resolve-anchors") && !opt.Called("preserve") {
+		fmt.Fprintf(os.Stderr, "ERROR: --resolve-anchors requires --preserve\n")
+		os.Exit(1)
+	}
+
+	// Writing the file back would replace every '!include' with the contents of the included file
+	if opt.Called("follow-includes") && (!opt.Called("file") || readStream || opt.Called("in-place")) {
+		fmt.Fprintf(os.Stderr, "ERROR: --follow-includes requires '--file <file>' and can't be used with --doc, --all-docs or --in-place\n")
+		os.Exit(1)
+	}
+
+	var docs []*yamlutils.YML
+	var stream *yamlutils.YMLStream
+	switch {
+	case readStream && readStdin:
+		logger.Printf("Reading stream from stdin\n")
+		stream, err = yamlutils.NewStreamFromReader(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: reading yaml from STDIN: %s\n", err)
+			os.Exit(1)
+		}
+		docs = stream.Docs
+	case readStream:
+		logger.Printf("Reading stream from file: %s\n", file)
+		stream, err = yamlutils.NewStreamFromFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: reading yaml file: %s\n", err)
+			os.Exit(1)
+		}
+		docs = stream.Docs
+	case readStdin:
+		yml, err := readYMLWithFormat("-", inFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: reading yaml from STDIN: %s\n", err)
+			os.Exit(1)
+		}
+		docs = []*yamlutils.YML{yml}
+	case opt.Called("follow-includes"):
+		logger.Printf("Reading from file with includes: %s\n", file)
+		yml, err := yamlutils.NewFromFileWithIncludes(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: reading yaml file: %s\n", err)
+			os.Exit(1)
+		}
+		docs = []*yamlutils.YML{yml}
+	default:
+		yml, err := readYMLWithFormat(file, inFormat)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: reading yaml file: %s\n", err)
 			os.Exit(1)
 		}
+		docs = []*yamlutils.YML{yml}
+	}
+	for i, yml := range docs {
+		yml.PreserveFormat = opt.Called("preserve")
+		yml.Format = format
+		if opt.Called("resolve-anchors") {
+			err = yml.ResolveAnchors()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+				os.Exit(1)
+			}
+		}
+		if opt.Called("expand") {
+			err = yml.Expand(yamlutils.ExpandOptions{})
+			if err != nil {
+				if opt.Called("all-docs") {
+					fmt.Fprintf(os.Stderr, "ERROR: document %d: %s\n", i, err)
+				} else {
+					fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+				}
+				os.Exit(1)
+			}
+		}
+	}
+	if opt.Called("doc") {
+		if docIndex < 0 || len(docs) <= docIndex {
+			fmt.Fprintf(os.Stderr, "ERROR: document %s: %d\n", yamlutils.ErrInvalidIndex, docIndex)
+			os.Exit(1)
+		}
+		docs = docs[docIndex : docIndex+1]
 	}
 
-	if opt.Called("add") {
-		str, err := yml.AddString(xpath, add)
+	var results []string
+	queryFailed := false
+	for i, yml := range docs {
+		var str string
+		switch {
+		case opt.Called("query"):
+			qResults := yml.Query(include, queries)
+			for _, r := range qResults {
+				if r.Err == nil {
+					continue
+				}
+				queryFailed = true
+				if opt.Called("all-docs") {
+					fmt.Fprintf(os.Stderr, "ERROR: document %d: query '%s': %s\n", i, r.Query, r.Err)
+				} else {
+					fmt.Fprintf(os.Stderr, "ERROR: query '%s': %s\n", r.Query, r.Err)
+				}
+			}
+			str, err = yamlutils.QueryString(qResults, qFormat, format)
+		case opt.Called("expression"):
+			str, err = yml.EvalString(expr)
+		case opt.Called("add"):
+			str, err = yml.AddString(xpath, add)
+		case opt.Called("set"):
+			str, err = yml.Set(xpath, set, opt.Called("create"))
+		case opt.Called("delete"):
+			str, err = yml.Delete(xpath)
+		case opt.Called("anchor"):
+			str, err = yml.AnchorOf(xpath)
+		case opt.Called("export"):
+			str, err = yml.Export(xpath, yamlutils.ExportOptions{Prefix: exportPrefix, Shell: exportShell})
+		case opt.Called("flatten"):
+			str, err = yml.Flatten(xpath, yamlutils.FlattenOptions{Separator: separator, Quote: opt.Called("quote")})
+			if errors.Is(err, yamlutils.ErrUnsupportedValue) {
+				err = fmt.Errorf("%w, use --quote", err)
+			}
+		case opt.Called("keys"):
+			str, err = yml.KeysString(xpath)
+		case opt.Called("length"):
+			str, err = yml.LenString(xpath)
+		case opt.Called("type"):
+			str, err = yml.KindString(xpath)
+		case opt.Called("paths"):
+			str, err = yml.GetMatchesString(xpath)
+		default:
+			str, err = yml.GetString(include, xpath)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			if opt.Called("all-docs") {
+				fmt.Fprintf(os.Stderr, "ERROR: document %d: %s\n", i, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			}
 			if !opt.Called("silent") {
 				fmt.Fprintf(os.Stderr, ">\t%s\n", strings.ReplaceAll(str, "\n", "\n>\t"))
 			}
 			os.Exit(1)
 		}
-		if opt.Called("n") {
-			str = strings.TrimSpace(str)
+		results = append(results, str)
+	}
+	if opt.Called("in-place") {
+		if stream != nil {
+			err = stream.WriteFileWithFormat(file, inFormat, backupSuffix)
+		} else {
+			err = docs[0].WriteFileWithFormat(file, inFormat, backupSuffix)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: writing yaml file: %s\n", err)
+			os.Exit(1)
 		}
-		fmt.Printf(str)
 		return
 	}
-
-	str, err := yml.GetString(include, xpath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
-		if !opt.Called("silent") {
-			fmt.Fprintf(os.Stderr, ">\t%s\n", strings.ReplaceAll(str, "\n", "\n>\t"))
+	str := strings.Join(results, "")
+	if opt.Called("all-docs") && !opt.Called("query") {
+		if format == yamlutils.FormatYAML {
+			str = yamlutils.JoinDocuments(results)
+		} else {
+			// One JSON document per line
+			str = ""
+			for _, r := range results {
+				str += strings.TrimSuffix(r, "\n") + "\n"
+			}
 		}
-		os.Exit(1)
 	}
 	if opt.Called("n") {
 		str = strings.TrimSpace(str)
 	}
-	fmt.Printf(str)
+	fmt.Print(str)
+	if queryFailed {
+		os.Exit(1)
+	}
+}
+
+// readYML reads the file in the format given by its extension, YAML by default, '-' reads YAML from STDIN.
+func readYML(file string) (*yamlutils.YML, error) {
+	return readYMLWithFormat(file, yamlutils.FileFormatFromName(file))
+}
+
+// readYMLWithFormat reads the file in the given format, '-' reads from STDIN.
+func readYMLWithFormat(file string, format yamlutils.FileFormat) (*yamlutils.YML, error) {
+	if file == "-" {
+		logger.Printf("Reading %s from stdin\n", format)
+		return yamlutils.NewFromReaderWithFormat(os.Stdin, format)
+	}
+	logger.Printf("Reading %s from file: %s\n", format, file)
+	return yamlutils.NewFromFileWithFormat(file, format)
 }
@@ -0,0 +1,102 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benedict-pureweb/go-utils/yamlutils"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func mergeCommand(args []string) int {
+	var output, lists string
+	opt := getoptions.New()
+	opt.Self("merge", `Deep merges the given YAML files, each file is merged on top of the previous ones.
+    Use '-' after '--' to read a file from STDIN, for example: 'yaml-parse merge -- base.yml -'.
+    A null value removes the key from the result.
+
+    Usage: yaml-parse merge [<options>] <base.yml> <overlay.yml>...`)
+	opt.Bool("help", false, opt.Alias("?"))
+	opt.Bool("n", false, opt.Description("Remove trailing spaces."))
+	opt.Bool("preserve", false, opt.Description("Preserve comments, key order and formatting of the base file."))
+	opt.StringVar(&output, "output", "yaml", opt.Alias("o"), opt.ArgName("yaml|json|json-pretty"),
+		opt.Description("Output format, json is compact JSON in a single line and json-pretty is indented JSON."))
+	opt.StringVar(&lists, "lists", "replace", opt.ArgName("replace|append|merge[:key]"),
+		opt.Description(`Default list strategy.
+merge matches list items by the given key, 'name' by default.`))
+	pathLists := opt.StringMap("path-lists", 1, 99, opt.ArgName("path=strategy"),
+		opt.Description(`List strategy for the list at the given path.
+Use '*' to match any key or index, for example: 'services/*/ports=append'.
+When several paths match, the one with less '*' wins, then the one with the longest path before the first '*'.`))
+	files, err := opt.Parse(args)
+	if opt.Called("help") {
+		fmt.Fprintln(os.Stderr, opt.Help())
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if len(files) < 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: missing files to merge\n")
+		return 1
+	}
+	format, err := yamlutils.ParseOutputFormat(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	opts := yamlutils.MergeOptions{Paths: map[string]yamlutils.ListMerge{}}
+	opts.Lists, err = yamlutils.ParseListMerge(lists)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	for path, strategy := range pathLists {
+		opts.Paths[path], err = yamlutils.ParseListMerge(strategy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: path '%s': %s\n", path, err)
+			return 1
+		}
+	}
+
+	var merged *yamlutils.YML
+	for _, file := range files {
+		yml, err := readYML(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: reading yaml file '%s': %s\n", file, err)
+			return 1
+		}
+		if merged == nil {
+			merged = yml
+			continue
+		}
+		merged, err = yamlutils.Merge(merged, yml, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: merging '%s': %s\n", file, err)
+			return 1
+		}
+	}
+	merged.PreserveFormat = opt.Called("preserve")
+	merged.Format = format
+	str, err := merged.Document()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if opt.Called("n") {
+		str = strings.TrimSpace(str)
+	}
+	fmt.Print(str)
+	return 0
+}
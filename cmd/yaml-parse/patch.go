@@ -0,0 +1,120 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/benedict-pureweb/go-utils/yamlutils"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func patchCommand(args []string) int {
+	var patchFile, patchType, output, backupSuffix string
+	opt := getoptions.New()
+	opt.Self("patch", `Applies a JSON Patch (RFC 6902) or a JSON Merge Patch (RFC 7386) to a YAML file.
+    The patch can be written in YAML or JSON.
+    The patch is applied atomically, if any operation fails nothing is changed.
+    Reads from STDIN when no file is given.
+
+    Usage: yaml-parse patch [<options>] --patch-file <patch> [<file.yml>]`)
+	opt.Bool("help", false, opt.Alias("?"))
+	opt.Bool("n", false, opt.Description("Remove trailing spaces."))
+	opt.Bool("preserve", false, opt.Description("Preserve comments, key order and formatting of the input."))
+	opt.Bool("in-place", false, opt.Alias("i"), opt.Description("Write the result back to the file instead of STDOUT."))
+	opt.StringVar(&backupSuffix, "backup", "", opt.ArgName("suffix"),
+		opt.Description("Keep a backup of the original file with the given suffix when using --in-place, for example '.bak'."))
+	opt.StringVar(&patchFile, "patch-file", "", opt.Alias("p"), opt.ArgName("file"), opt.Required(),
+		opt.Description("Patch file to apply."))
+	opt.StringVar(&patchType, "type", "auto", opt.ArgName("auto|json-patch|merge-patch"),
+		opt.Description(`Patch type.
+auto uses json-patch when the patch is a list and merge-patch otherwise.`))
+	opt.StringVar(&output, "output", "yaml", opt.Alias("o"), opt.ArgName("yaml|json|json-pretty"),
+		opt.Description("Output format, json is compact JSON in a single line and json-pretty is indented JSON."))
+	files, err := opt.Parse(args)
+	if opt.Called("help") {
+		fmt.Fprintln(os.Stderr, opt.Help())
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: patch accepts a single file\n")
+		return 1
+	}
+	format, err := yamlutils.ParseOutputFormat(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	file := "-"
+	if len(files) == 1 {
+		file = files[0]
+	}
+	if opt.Called("in-place") && file == "-" {
+		fmt.Fprintf(os.Stderr, "ERROR: --in-place requires a file\n")
+		return 1
+	}
+	if f := yamlutils.FileFormatFromName(file); opt.Called("in-place") && f != yamlutils.FileYAML && f != yamlutils.FileJSON {
+		fmt.Fprintf(os.Stderr, "ERROR: --in-place requires a YAML or JSON file\n")
+		return 1
+	}
+	yml, err := readYML(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: reading yaml file '%s': %s\n", file, err)
+		return 1
+	}
+	patch, err := readYML(patchFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: reading patch file '%s': %s\n", patchFile, err)
+		return 1
+	}
+	yml.PreserveFormat = opt.Called("preserve")
+	yml.Format = format
+
+	if patchType == "auto" {
+		patchType = "merge-patch"
+		if _, ok := patch.Tree.([]interface{}); ok {
+			patchType = "json-patch"
+		}
+	}
+	logger.Printf("patch type: %s\n", patchType)
+	var str string
+	switch patchType {
+	case "json-patch":
+		str, err = yml.Patch(patch)
+	case "merge-patch":
+		str, err = yml.MergePatch(patch)
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: invalid patch type: %s\n", patchType)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if opt.Called("in-place") {
+		err = yml.WriteFile(file, backupSuffix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: writing yaml file: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+	if opt.Called("n") {
+		str = strings.TrimSpace(str)
+	}
+	fmt.Print(str)
+	return 0
+}
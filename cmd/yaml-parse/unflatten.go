@@ -0,0 +1,85 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/benedict-pureweb/go-utils/yamlutils"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func unflattenCommand(args []string) int {
+	var output, separator string
+	opt := getoptions.New()
+	opt.Self("unflatten", `Builds a YAML document from 'path=value' lines, the inverse of 'yaml-parse --flatten'.
+    Reads from STDIN when no file is given or the file is '-' after '--'.
+    Maps whose keys are the indexes 0 to n-1 become lists.
+
+    Usage: yaml-parse unflatten [<options>] [<file>]`)
+	opt.Bool("help", false, opt.Alias("?"))
+	opt.Bool("n", false, opt.Description("Remove trailing spaces."))
+	opt.StringVar(&separator, "separator", yamlutils.DefaultFlattenSeparator, opt.ArgName("separator"),
+		opt.Description("Separator between the path and the value."))
+	opt.Bool("quote", false, opt.Description("Values are JSON, as written by 'yaml-parse --flatten --quote'."))
+	opt.StringVar(&output, "output", "yaml", opt.Alias("o"), opt.ArgName("yaml|json|json-pretty"),
+		opt.Description("Output format, json is compact JSON in a single line and json-pretty is indented JSON."))
+	files, err := opt.Parse(args)
+	if opt.Called("help") {
+		fmt.Fprintln(os.Stderr, opt.Help())
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if len(files) > 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: too many files: %s\n", strings.Join(files, ", "))
+		return 1
+	}
+	format, err := yamlutils.ParseOutputFormat(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	var data []byte
+	if len(files) == 0 || files[0] == "-" {
+		logger.Printf("Reading from stdin\n")
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		logger.Printf("Reading from file: %s\n", files[0])
+		data, err = ioutil.ReadFile(files[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	lines := strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n")
+	tree, err := yamlutils.Unflatten(lines, yamlutils.FlattenOptions{Separator: separator, Quote: opt.Called("quote")})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	yml := &yamlutils.YML{Tree: tree, Format: format}
+	str, err := yml.Document()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if opt.Called("n") {
+		str = strings.TrimSpace(str)
+	}
+	fmt.Print(str)
+	return 0
+}
@@ -0,0 +1,79 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/benedict-pureweb/go-utils/yamlutils"
+
+	"github.com/DavidGamba/go-getoptions"
+)
+
+func validateCommand(args []string) int {
+	var schemaFile string
+	opt := getoptions.New()
+	opt.Self("validate", `Validates YAML files against a JSON Schema (draft 7 or later).
+    Every violation is reported with the path of the offending element.
+    Reads from STDIN when no file is given.
+
+    Exit status is 0 when all the files are valid, 1 when there are violations and 2 on errors.
+
+    Usage: yaml-parse validate [<options>] --schema <schema> [<file.yml>...]`)
+	opt.Bool("help", false, opt.Alias("?"))
+	opt.Bool("quiet", false, opt.Alias("q"), opt.Description("Only report the result through the exit status."))
+	opt.StringVar(&schemaFile, "schema", "", opt.Alias("s"), opt.ArgName("file"), opt.Required(),
+		opt.Description("JSON Schema file, in JSON or YAML."))
+	files, err := opt.Parse(args)
+	if opt.Called("help") {
+		fmt.Fprintln(os.Stderr, opt.Help())
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 2
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	schema, err := yamlutils.NewSchemaFromFile(schemaFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: reading schema file '%s': %s\n", schemaFile, err)
+		return 2
+	}
+
+	status := 0
+	for _, file := range files {
+		yml, err := readYML(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: reading yaml file '%s': %s\n", file, err)
+			return 2
+		}
+		violations, err := yml.Validate(schema)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			return 2
+		}
+		logger.Printf("%s: violations: %d\n", file, len(violations))
+		if len(violations) > 0 {
+			status = 1
+		}
+		if opt.Called("quiet") {
+			continue
+		}
+		for _, v := range violations {
+			if len(files) > 1 {
+				fmt.Printf("%s: ", file)
+			}
+			fmt.Println(v)
+		}
+	}
+	return status
+}
@@ -116,6 +116,42 @@ func CopyFile(src, dst string) error {
 	return err
 }
 
+// WriteFileAtomic writes data to a temporary file in the same directory as filename and then renames it over
+// filename, readers either see the old or the new contents, never a partially written file.
+// The file mode of an existing file is preserved, perm is used for new files.
+// When filename is a symlink, the file it points to is replaced.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	info, err := os.Stat(filename)
+	if err == nil {
+		perm = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if cerr := tmpFile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmpFile.Name(), perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filename)
+}
+
 // GetFileList returns a channel with each file (`channel.String`) or an error indicating failure (`channel.Error`).
 func GetFileList(dirname string, ignoreDirs, recursive bool) <-chan StringError {
 	c := make(chan StringError)
@@ -3,6 +3,8 @@ module github.com/benedict-pureweb/go-utils
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/DavidGamba/go-getoptions v0.16.0
 	gopkg.in/yaml.v2 v2.2.4
+	gopkg.in/yaml.v3 v3.0.1
 )
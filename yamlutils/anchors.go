@@ -0,0 +1,215 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Anchors, aliases and merge keys are already resolved in the Tree when reading: aliases are replaced by a copy of
+// the anchored element and '<<' merge keys by the effective map, where explicit keys take precedence over merged
+// ones and earlier maps in a merge list take precedence over later ones.
+// The functions in this file work with the original source to report where values came from and to produce a
+// flattened source.
+
+// AnchorOf returns the name of the anchor the element at the given path came from, either through an alias or
+// through a '<<' merge key.
+// When the path goes through several aliases, the innermost one is returned.
+// An empty string is returned when the element is defined in place.
+// The original source is used, changes made to the Tree are not reflected.
+func (y *YML) AnchorOf(keys []string) (string, error) {
+	matches, err := FindAll(y.Tree, keys)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("%w: %s", ErrMultipleMatches, JoinPath(keys))
+	}
+	if y.source == nil {
+		return "", nil
+	}
+	var doc yaml3.Node
+	err = yaml3.Unmarshal(y.source, &doc)
+	if err != nil || len(doc.Content) == 0 {
+		return "", err
+	}
+	node, anchor := doc.Content[0], ""
+	for _, e := range matches[0].Path {
+		node, anchor = followAlias(node, anchor)
+		var next *yaml3.Node
+		switch node.Kind {
+		case yaml3.MappingNode:
+			key, _ := literalKey(e)
+			next, anchor = mappingValue(node, key, anchor)
+		case yaml3.SequenceNode:
+			i, err := strconv.Atoi(e)
+			if err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+			}
+		}
+		if next == nil {
+			// The Tree was modified after reading
+			return "", fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(e))
+		}
+		node = next
+	}
+	_, anchor = followAlias(node, anchor)
+	return anchor, nil
+}
+
+func followAlias(node *yaml3.Node, anchor string) (*yaml3.Node, string) {
+	for node.Kind == yaml3.AliasNode && node.Alias != nil {
+		anchor = node.Value
+		node = node.Alias
+	}
+	return node, anchor
+}
+
+func isMergeKey(key *yaml3.Node) bool {
+	return key.Kind == yaml3.ScalarNode && key.Value == "<<" && (key.Tag == "!!merge" || key.Tag == "")
+}
+
+// mappingValue returns the value node for key, looking into merged maps when it is not an explicit key.
+// The returned anchor is the anchor of the merged map that provided the value.
+func mappingValue(node *yaml3.Node, key, anchor string) (*yaml3.Node, string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i]
+		if !isMergeKey(k) && k.Value == key {
+			return node.Content[i+1], anchor
+		}
+	}
+	for _, merged := range mergedNodes(node) {
+		m, a := followAlias(merged, anchor)
+		if m.Kind != yaml3.MappingNode {
+			continue
+		}
+		if v, a := mappingValue(m, key, a); v != nil {
+			return v, a
+		}
+	}
+	return nil, anchor
+}
+
+// mergedNodes returns the maps referenced by the '<<' merge keys of the mapping node in precedence order.
+func mergedNodes(node *yaml3.Node) []*yaml3.Node {
+	nodes := []*yaml3.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isMergeKey(node.Content[i]) {
+			continue
+		}
+		v := node.Content[i+1]
+		if v.Kind == yaml3.SequenceNode {
+			nodes = append(nodes, v.Content...)
+		} else {
+			nodes = append(nodes, v)
+		}
+	}
+	return nodes
+}
+
+// ResolveAnchors replaces the source used to preserve the format with a flattened version where aliases are
+// replaced by a copy of the anchored element, merge keys by the merged keys and anchors are removed.
+// Comments are kept.
+// The Tree is not modified, it is already resolved when reading.
+func (y *YML) ResolveAnchors() error {
+	if y.source == nil {
+		return nil
+	}
+	out, err := ResolveAnchors(y.source)
+	if err != nil {
+		return err
+	}
+	y.source = out
+	return nil
+}
+
+// ResolveAnchors returns the YAML document with all the anchors, aliases and merge keys resolved.
+func ResolveAnchors(src []byte) ([]byte, error) {
+	var doc yaml3.Node
+	err := yaml3.Unmarshal(src, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return src, nil
+	}
+	doc.Content[0] = resolveNode(doc.Content[0])
+	return encodeNode(&doc)
+}
+
+// encodeNode returns the YAML representation of the node using 2 spaces indentation.
+func encodeNode(node *yaml3.Node) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml3.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err := encoder.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	return b.Bytes(), err
+}
+
+// resolveNode returns a copy of the node with aliases and merge keys resolved.
+func resolveNode(node *yaml3.Node) *yaml3.Node {
+	if node.Kind == yaml3.AliasNode && node.Alias != nil {
+		r := resolveNode(node.Alias)
+		// Keep the comments written next to the alias
+		r.HeadComment, r.LineComment, r.FootComment = node.HeadComment, node.LineComment, node.FootComment
+		return r
+	}
+	r := *node
+	r.Anchor = ""
+	r.Content = nil
+	switch node.Kind {
+	case yaml3.MappingNode:
+		explicit := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !isMergeKey(node.Content[i]) {
+				explicit[node.Content[i].Value] = true
+			}
+		}
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if !isMergeKey(k) {
+				r.Content = append(r.Content, resolveNode(k), resolveNode(v))
+				continue
+			}
+			// Merged keys are placed where the merge key was
+			merged := []*yaml3.Node{v}
+			if v.Kind == yaml3.SequenceNode {
+				merged = v.Content
+			}
+			for _, m := range merged {
+				m = resolveNode(m)
+				if m.Kind != yaml3.MappingNode {
+					continue
+				}
+				for j := 0; j+1 < len(m.Content); j += 2 {
+					key := m.Content[j].Value
+					if explicit[key] || seen[key] {
+						continue
+					}
+					seen[key] = true
+					r.Content = append(r.Content, m.Content[j], m.Content[j+1])
+				}
+			}
+		}
+	default:
+		for _, c := range node.Content {
+			r.Content = append(r.Content, resolveNode(c))
+		}
+	}
+	return &r
+}
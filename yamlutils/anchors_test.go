@@ -0,0 +1,157 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var anchorsDoc = `defaults: &defaults
+  timeout: 30
+  retries: 3
+  labels: &labels
+    team: core
+extra: &extra
+  retries: 5
+  debug: false
+api:
+  <<: *defaults
+  retries: 1
+web:
+  <<: [*extra, *defaults]
+  labels: *labels
+ports: &ports [80, 443]
+proxy:
+  ports: *ports
+`
+
+func TestMergeKeysResolvedOnRead(t *testing.T) {
+	y, err := NewFromString(anchorsDoc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	tests := []struct {
+		path     []string
+		expected interface{}
+	}{
+		{[]string{"api"}, map[interface{}]interface{}{"timeout": 30, "retries": 1,
+			"labels": map[interface{}]interface{}{"team": "core"}}},
+		{[]string{"web", "retries"}, 5},
+		{[]string{"web", "timeout"}, 30},
+		{[]string{"proxy", "ports", "1"}, 443},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, _, err := NavigateTree(false, y.Tree, test.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestAnchorOf(t *testing.T) {
+	y, err := NewFromString(anchorsDoc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	tests := []struct {
+		path     []string
+		expected string
+		err      error
+	}{
+		{[]string{"defaults", "timeout"}, "", nil},
+		{[]string{"api", "timeout"}, "defaults", nil},
+		{[]string{"api", "retries"}, "", nil},
+		{[]string{"api", "labels"}, "defaults", nil},
+		{[]string{"api", "labels", "team"}, "defaults", nil},
+		{[]string{"web", "retries"}, "extra", nil},
+		{[]string{"web", "timeout"}, "defaults", nil},
+		{[]string{"web", "labels"}, "labels", nil},
+		{[]string{"proxy", "ports"}, "ports", nil},
+		{[]string{"proxy", "ports", "-1"}, "ports", nil},
+		{[]string{"api", "missing"}, "", ErrMapKeyNotFound},
+		{[]string{"*", "timeout"}, "", ErrMultipleMatches},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.AnchorOf(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: '%s', got: '%s'\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestResolveAnchors(t *testing.T) {
+	y, err := NewFromString(anchorsDoc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	err = y.ResolveAnchors()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := `defaults:
+  timeout: 30
+  retries: 3
+  labels:
+    team: core
+extra:
+  retries: 5
+  debug: false
+api:
+  timeout: 30
+  labels:
+    team: core
+  retries: 1
+web:
+  retries: 5
+  debug: false
+  timeout: 30
+  labels:
+    team: core
+ports: [80, 443]
+proxy:
+  ports: [80, 443]
+`
+	y.PreserveFormat = true
+	output, err := y.Document()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+	// The flattened source must represent the same Tree
+	resolved, _ := NewFromString(output)
+	if !reflect.DeepEqual(resolved.Tree, y.Tree) {
+		t.Errorf("Resolved tree differs:\n%#v\n%#v\n", resolved.Tree, y.Tree)
+	}
+}
+
+func TestResolveAnchorsComments(t *testing.T) {
+	input := "# head\nbase: &b\n  a: 1 # one\nchild:\n  <<: *b # merged\n  c: 2\n"
+	expected := "# head\nbase:\n  a: 1 # one\nchild:\n  a: 1 # one\n  c: 2\n"
+	output, err := ResolveAnchors([]byte(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(output))
+	}
+}
@@ -0,0 +1,242 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// FileFormat - Format of a document that can be read into or written from a YML object.
+type FileFormat string
+
+const (
+	// FileYAML - YAML document.
+	FileYAML FileFormat = "yaml"
+	// FileJSON - JSON document.
+	FileJSON FileFormat = "json"
+	// FileTOML - TOML document.
+	FileTOML FileFormat = "toml"
+	// FileProperties - Java properties file.
+	FileProperties FileFormat = "properties"
+	// FileDotenv - dotenv file with one 'KEY=value' assignment per line.
+	FileDotenv FileFormat = "dotenv"
+)
+
+// ErrInvalidInput - The input doesn't follow the syntax of its format.
+var ErrInvalidInput = fmt.Errorf("invalid input")
+
+// ErrUnsupportedValue - The Tree has a value that can't be represented in the requested format.
+var ErrUnsupportedValue = fmt.Errorf("unsupported value")
+
+// ParseFileFormat returns the FileFormat for the given name.
+// Valid names are: yaml, yml, json, toml, properties, props, dotenv and env.
+func ParseFileFormat(name string) (FileFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "yaml", "yml":
+		return FileYAML, nil
+	case "json":
+		return FileJSON, nil
+	case "toml":
+		return FileTOML, nil
+	case "properties", "props":
+		return FileProperties, nil
+	case "dotenv", "env":
+		return FileDotenv, nil
+	}
+	return FileYAML, fmt.Errorf("%w: %s", ErrInvalidFormat, name)
+}
+
+// FileFormatFromName returns the FileFormat matching the extension of filename.
+// Files named '.env' or starting with '.env.', like '.env.local', are dotenv files.
+// Unknown extensions return FileYAML.
+func FileFormatFromName(filename string) FileFormat {
+	base := filepath.Base(filename)
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return FileDotenv
+	}
+	ext := strings.TrimPrefix(filepath.Ext(base), ".")
+	if ext == "" {
+		return FileYAML
+	}
+	format, err := ParseFileFormat(ext)
+	if err != nil {
+		return FileYAML
+	}
+	return format
+}
+
+// NewFromFileWithFormat returns a pointer to a YML object from a file in the given format.
+func NewFromFileWithFormat(filename string, format FileFormat) (*YML, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromBytes(data, format)
+}
+
+// NewFromReaderWithFormat returns a pointer to a YML object from an io.Reader in the given format.
+func NewFromReaderWithFormat(reader io.Reader, format FileFormat) (*YML, error) {
+	buf := new(bytes.Buffer)
+	_, err := buf.ReadFrom(reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromBytes(buf.Bytes(), format)
+}
+
+// NewFromBytes returns a pointer to a YML object from data in the given format.
+// The resulting Tree uses the same types as a YAML document so all the YML operations work the same regardless of
+// the input format:
+// JSON and TOML integers become int, TOML dates become RFC 3339 strings.
+// Properties and dotenv values are always strings, see ReadProperties and ReadDotenv.
+// Only YAML input keeps its source to preserve the format.
+func NewFromBytes(data []byte, format FileFormat) (*YML, error) {
+	var tree interface{}
+	var err error
+	switch format {
+	case FileYAML:
+		return NewFromString(string(data))
+	case FileJSON:
+		tree, err = readJSON(data)
+	case FileTOML:
+		tree, err = readTOML(data)
+	case FileProperties:
+		tree, err = ReadProperties(data)
+	case FileDotenv:
+		tree, err = ReadDotenv(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &YML{Tree: tree}, nil
+}
+
+// Encode returns the full document represented by the Tree in the given format.
+// YAML output honours PreserveFormat, JSON output is indented.
+// TOML, properties and dotenv documents must be maps, TOML drops null map values.
+func (y *YML) Encode(format FileFormat) ([]byte, error) {
+	switch format {
+	case FileYAML:
+		if y.PreserveFormat && y.source != nil {
+			return marshalPreserved(y.source, y.Tree)
+		}
+		return yaml.Marshal(y.Tree)
+	case FileJSON:
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(JSONCompatible(y.Tree))
+		return buf.Bytes(), err
+	case FileTOML:
+		return writeTOML(y.Tree)
+	case FileProperties:
+		return WriteProperties(y.Tree)
+	case FileDotenv:
+		return WriteDotenv(y.Tree)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+}
+
+func readJSON(data []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	err := dec.Decode(&tree)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: extra data after the JSON document", ErrInvalidInput)
+	}
+	return yamlCompatible(tree), nil
+}
+
+func readTOML(data []byte) (interface{}, error) {
+	tree := map[string]interface{}{}
+	_, err := toml.Decode(string(data), &tree)
+	if err != nil {
+		return nil, err
+	}
+	return yamlCompatible(tree), nil
+}
+
+func writeTOML(tree interface{}) ([]byte, error) {
+	if tree == nil {
+		return []byte{}, nil
+	}
+	m, ok := JSONCompatible(tree).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: TOML document must be a map, found %s", ErrUnsupportedValue, kindOf(tree))
+	}
+	buf := new(bytes.Buffer)
+	err := toml.NewEncoder(buf).Encode(m)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedValue, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlCompatible returns a copy of the tree decoded by encoding/json or toml using the types of a YAML Tree.
+// It is the inverse of JSONCompatible.
+func yamlCompatible(m interface{}) interface{} {
+	switch t := m.(type) {
+	case map[string]interface{}:
+		r := make(map[interface{}]interface{}, len(t))
+		for k, v := range t {
+			r[k] = yamlCompatible(v)
+		}
+		return r
+	case []map[string]interface{}:
+		r := make([]interface{}, len(t))
+		for i, v := range t {
+			r[i] = yamlCompatible(v)
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(t))
+		for i, v := range t {
+			r[i] = yamlCompatible(v)
+		}
+		return r
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return yamlCompatible(i)
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return t.String()
+		}
+		return f
+	case int64:
+		if int64(int(t)) == t {
+			return int(t)
+		}
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return m
+	}
+}
@@ -0,0 +1,308 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFileFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected FileFormat
+		err      error
+	}{
+		{"", FileYAML, nil},
+		{"YML", FileYAML, nil},
+		{"json", FileJSON, nil},
+		{"toml", FileTOML, nil},
+		{"props", FileProperties, nil},
+		{"env", FileDotenv, nil},
+		{"xml", FileYAML, ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := ParseFileFormat(test.name)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestFileFormatFromName(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected FileFormat
+	}{
+		{"config.yml", FileYAML},
+		{"/etc/app/config.json", FileJSON},
+		{"Cargo.toml", FileTOML},
+		{"app.properties", FileProperties},
+		{".env", FileDotenv},
+		{"dir/.env.local", FileDotenv},
+		{"prod.env", FileDotenv},
+		{"README", FileYAML},
+		{"data.xml", FileYAML},
+		{"-", FileYAML},
+	}
+	for _, test := range tests {
+		t.Run(test.filename, func(t *testing.T) {
+			output := FileFormatFromName(test.filename)
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestNewFromBytes(t *testing.T) {
+	expected := map[interface{}]interface{}{
+		"name":  "api",
+		"port":  8080,
+		"ratio": 0.5,
+		"tls":   true,
+		"hosts": []interface{}{"a", "b"},
+		"db":    map[interface{}]interface{}{"host": "localhost"},
+	}
+	tests := []struct {
+		name   string
+		format FileFormat
+		input  string
+	}{
+		{"yaml", FileYAML, `
+name: api
+port: 8080
+ratio: 0.5
+tls: true
+hosts: [a, b]
+db:
+  host: localhost
+`},
+		{"json", FileJSON, `{
+	"name": "api",
+	"port": 8080,
+	"ratio": 0.5,
+	"tls": true,
+	"hosts": ["a", "b"],
+	"db": {"host": "localhost"}
+}`},
+		{"toml", FileTOML, `
+name = "api"
+port = 8080
+ratio = 0.5
+tls = true
+hosts = ["a", "b"]
+
+[db]
+host = "localhost"
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y, err := NewFromBytes([]byte(test.input), test.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(y.Tree, expected) {
+				t.Errorf("Expected: %#v, got: %#v\n", expected, y.Tree)
+			}
+			// The Tree works with the regular YML operations
+			host, err := y.GetString(false, []string{"db", "host"})
+			if err != nil || host != "localhost" {
+				t.Errorf("Unexpected result: %s, %v\n", host, err)
+			}
+		})
+	}
+}
+
+func TestNewFromBytesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format FileFormat
+		input  string
+		err    error
+	}{
+		{"json extra data", FileJSON, `{"a": 1} {"b": 2}`, ErrInvalidInput},
+		{"properties empty element", FileProperties, "a..b=1\n", ErrInvalidInput},
+		{"dotenv missing value", FileDotenv, "A\n", ErrInvalidInput},
+		{"unknown format", FileFormat("xml"), "<a/>", ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewFromBytes([]byte(test.input), test.format)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+		})
+	}
+}
+
+func TestNewFromBytesTOMLTypes(t *testing.T) {
+	y, err := NewFromBytes([]byte(`
+when = 1979-05-27T07:32:00Z
+
+[[servers]]
+name = "a"
+
+[[servers]]
+name = "b"
+`), FileTOML)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := map[interface{}]interface{}{
+		"when": "1979-05-27T07:32:00Z",
+		"servers": []interface{}{
+			map[interface{}]interface{}{"name": "a"},
+			map[interface{}]interface{}{"name": "b"},
+		},
+	}
+	if !reflect.DeepEqual(y.Tree, expected) {
+		t.Errorf("Expected: %#v, got: %#v\n", expected, y.Tree)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	y, _ := NewFromString(`
+name: api
+port: 8080
+db:
+  host: localhost
+  user: null
+servers:
+  - name: a
+  - name: b
+`)
+	tests := []struct {
+		format   FileFormat
+		expected string
+	}{
+		{FileYAML, `db:
+  host: localhost
+  user: null
+name: api
+port: 8080
+servers:
+- name: a
+- name: b
+`},
+		{FileJSON, `{
+  "db": {
+    "host": "localhost",
+    "user": null
+  },
+  "name": "api",
+  "port": 8080,
+  "servers": [
+    {
+      "name": "a"
+    },
+    {
+      "name": "b"
+    }
+  ]
+}
+`},
+		{FileTOML, `name = "api"
+port = 8080
+
+[db]
+  host = "localhost"
+
+[[servers]]
+  name = "a"
+
+[[servers]]
+  name = "b"
+`},
+		{FileProperties, `db.host=localhost
+db.user=
+name=api
+port=8080
+servers[0].name=a
+servers[1].name=b
+`},
+		{FileDotenv, `db_host=localhost
+db_user=
+name=api
+port=8080
+servers_0_name=a
+servers_1_name=b
+`},
+	}
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			output, err := y.Encode(test.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if string(output) != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format FileFormat
+		err    error
+	}{
+		{"toml list root", "[a, b]", FileTOML, ErrUnsupportedValue},
+		{"toml mixed list", "a: [1, x]", FileTOML, ErrUnsupportedValue},
+		{"properties scalar root", "a", FileProperties, ErrUnsupportedValue},
+		{"dotenv list root", "[a]", FileDotenv, ErrUnsupportedValue},
+		{"dotenv invalid name", "a b: c", FileDotenv, ErrUnsupportedValue},
+		{"unknown format", "a: b", FileFormat("xml"), ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y, _ := NewFromString(test.input)
+			_, err := y.Encode(test.format)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+		})
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	y, _ := NewFromString(`
+db:
+  host: "local host"
+  password: "p@ss=#1 \\ \"x\" $HOME"
+notes: "line 1\nline 2"
+servers:
+  - name: a
+  - name: b
+`)
+	for _, format := range []FileFormat{FileYAML, FileJSON, FileTOML, FileProperties} {
+		t.Run(string(format), func(t *testing.T) {
+			out, err := y.Encode(format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			r, err := NewFromBytes(out, format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(r.Tree, y.Tree) {
+				t.Errorf("Expected: %#v, got: %#v\n", y.Tree, r.Tree)
+			}
+		})
+	}
+}
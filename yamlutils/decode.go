@@ -0,0 +1,48 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Decode navigates to the element at the given path and decodes it into out, usually a pointer to a struct with yaml
+// tags.
+// Unknown fields are ignored, see DecodeStrict.
+func (y *YML) Decode(keys []string, out interface{}) error {
+	return y.decode(keys, out, false)
+}
+
+// DecodeStrict works like Decode but fails when the element has fields that are not present in out or when a
+// mapping has duplicate keys.
+func (y *YML) DecodeStrict(keys []string, out interface{}) error {
+	return y.decode(keys, out, true)
+}
+
+func (y *YML) decode(keys []string, out interface{}, strict bool) error {
+	target, _, err := NavigateTree(false, y.Tree, keys)
+	if err != nil {
+		return fmt.Errorf("yaml path '%s': %w", JoinPath(keys), err)
+	}
+	data, err := yaml.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("failed to Marshal yaml path '%s': %w", JoinPath(keys), err)
+	}
+	if strict {
+		err = yaml.UnmarshalStrict(data, out)
+	} else {
+		err = yaml.Unmarshal(data, out)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode yaml path '%s': %w", JoinPath(keys), err)
+	}
+	return nil
+}
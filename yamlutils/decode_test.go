@@ -0,0 +1,90 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type decodeDatabase struct {
+	Host    string            `yaml:"host"`
+	Port    int               `yaml:"port"`
+	Options map[string]string `yaml:"options"`
+	Replica []string          `yaml:"replicas"`
+}
+
+var decodeDoc = `
+app:
+  name: api
+database:
+  host: localhost
+  port: 5432
+  options:
+    sslmode: disable
+  replicas: [r1, r2]
+databases:
+  - host: a
+    port: 1
+    user: admin
+`
+
+func TestDecode(t *testing.T) {
+	y, _ := NewFromString(decodeDoc)
+	tests := []struct {
+		name     string
+		path     []string
+		strict   bool
+		expected decodeDatabase
+		err      error
+	}{
+		{"map", []string{"database"}, false, decodeDatabase{"localhost", 5432, map[string]string{"sslmode": "disable"}, []string{"r1", "r2"}}, nil},
+		{"strict", []string{"database"}, true, decodeDatabase{"localhost", 5432, map[string]string{"sslmode": "disable"}, []string{"r1", "r2"}}, nil},
+		{"list item", []string{"databases", "[host=a]"}, false, decodeDatabase{Host: "a", Port: 1}, nil},
+		{"missing key", []string{"db"}, false, decodeDatabase{}, ErrMapKeyNotFound},
+		{"extra elements", []string{"app", "name", "x"}, false, decodeDatabase{}, ErrExtraElementsInPath},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output decodeDatabase
+			var err error
+			if test.strict {
+				err = y.DecodeStrict(test.path, &output)
+			} else {
+				err = y.Decode(test.path, &output)
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	y, _ := NewFromString(decodeDoc)
+	var output decodeDatabase
+	err := y.DecodeStrict([]string{"databases", "0"}, &output)
+	if err == nil {
+		t.Errorf("Expected unknown field error\n")
+	}
+	y, _ = NewFromString("database:\n  port: abc\n")
+	err = y.Decode([]string{"database"}, &output)
+	if err == nil {
+		t.Errorf("Expected error\n")
+	}
+	var list []string
+	err = y.Decode([]string{"database"}, &list)
+	if err == nil {
+		t.Errorf("Expected error\n")
+	}
+}
@@ -0,0 +1,325 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ChangeType - Kind of difference between two trees.
+type ChangeType string
+
+const (
+	// ChangeAdded - The element only exists in the second tree.
+	ChangeAdded ChangeType = "added"
+	// ChangeRemoved - The element only exists in the first tree.
+	ChangeRemoved ChangeType = "removed"
+	// ChangeChanged - The element exists in both trees with a different value.
+	ChangeChanged ChangeType = "changed"
+)
+
+// Change - Difference found at the given path.
+type Change struct {
+	Type ChangeType
+	Path []string
+	// From - Value in the first tree, nil when added.
+	From interface{}
+	// To - Value in the second tree, nil when removed.
+	To interface{}
+}
+
+// Diff returns the structural differences between a and b.
+// See DiffTrees.
+func Diff(a, b *YML) []Change {
+	return DiffTrees(a.Tree, b.Tree)
+}
+
+// DiffTrees returns the structural differences between a and b.
+// Map keys are compared regardless of their order and list items are compared by index.
+// When the type of an element changes, a single change is reported for the element instead of one for each of its
+// children.
+// Changes are sorted by path with map keys in alphabetical order.
+func DiffTrees(a, b interface{}) []Change {
+	changes := []Change{}
+	diffTrees(a, b, []string{}, &changes)
+	return changes
+}
+
+func diffTrees(a, b interface{}, path []string, changes *[]Change) {
+	switch at := a.(type) {
+	case map[interface{}]interface{}:
+		bt, ok := b.(map[interface{}]interface{})
+		if !ok {
+			break
+		}
+		// Keys are compared by value so the int key 1 and the string key "1" are different keys
+		keys := map[interface{}]interface{}{}
+		for k := range at {
+			keys[k] = nil
+		}
+		for k := range bt {
+			keys[k] = nil
+		}
+		for _, k := range sortedKeys(keys) {
+			p := appendPath(path, diffKey(k))
+			av, aOk := at[k]
+			bv, bOk := bt[k]
+			switch {
+			case !bOk:
+				*changes = append(*changes, Change{Type: ChangeRemoved, Path: p, From: av})
+			case !aOk:
+				*changes = append(*changes, Change{Type: ChangeAdded, Path: p, To: bv})
+			default:
+				diffTrees(av, bv, p, changes)
+			}
+		}
+		return
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i, c := range children(at) {
+			p := appendPath(path, c.Path[0])
+			if i >= len(bt) {
+				*changes = append(*changes, Change{Type: ChangeRemoved, Path: p, From: c.Value})
+				continue
+			}
+			diffTrees(c.Value, bt[i], p, changes)
+		}
+		for i, c := range children(bt) {
+			if i >= len(at) {
+				*changes = append(*changes, Change{Type: ChangeAdded, Path: appendPath(path, c.Path[0]), To: c.Value})
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Type: ChangeChanged, Path: path, From: a, To: b})
+	}
+}
+
+// String returns a human readable representation of the change.
+// For example:
+//
+//	+ spec/replicas: 3
+//	- metadata/labels/app: "api"
+//	~ image/tag: "1.0" -> "2.0"
+func (c Change) String() string {
+	path := diffPath(c.Path)
+	if path == "" {
+		path = "."
+	}
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", path, diffValue(c.To))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", path, diffValue(c.From))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", path, diffValue(c.From), diffValue(c.To))
+	}
+}
+
+// diffKey returns the path element for the map key k.
+// String keys that read as another type, like '8080' or 'true', are returned as literal keys so they can be told
+// apart from the int or bool key with the same text.
+func diffKey(k interface{}) string {
+	s, ok := k.(string)
+	if !ok {
+		return pathKey(k)
+	}
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err == nil {
+		if _, isString := v.(string); isString {
+			return pathKey(k)
+		}
+	}
+	return LiteralKey(s)
+}
+
+// diffPath returns the path as shown in the changes, literal keys are quoted so '8080' and 8080 look different.
+// The result is still accepted by SplitPath.
+func diffPath(p []string) string {
+	elements := make([]string, len(p))
+	for i, e := range p {
+		key, literal := literalKey(e)
+		if !literal {
+			elements[i] = JoinPath([]string{e})
+			continue
+		}
+		elements[i] = `'` + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + `'`
+	}
+	return strings.Join(elements, string(PathSeparator))
+}
+
+// diffValue returns the value in compact JSON form so strings and numbers can be told apart.
+func diffValue(v interface{}) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(JSONCompatible(v))
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// DiffString returns the human readable representation of the changes, one per line.
+func DiffString(changes []Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// DiffJSON returns the changes as a JSON list of objects with the type, path, from and to keys.
+// The format selects between compact and pretty JSON, FormatYAML returns pretty JSON.
+func DiffJSON(changes []Change, format OutputFormat) (string, error) {
+	list := make([]interface{}, len(changes))
+	for i, c := range changes {
+		m := map[string]interface{}{"type": string(c.Type), "path": diffPath(c.Path)}
+		if c.Type != ChangeAdded {
+			m["from"] = c.From
+		}
+		if c.Type != ChangeRemoved {
+			m["to"] = c.To
+		}
+		list[i] = m
+	}
+	y := &YML{Format: format}
+	if !y.jsonOutput() {
+		y.Format = FormatJSONPretty
+	}
+	out, err := y.marshal(list)
+	return string(out), err
+}
+
+// UnifiedDiff returns a unified diff between the normalized YAML representation of a and b.
+// Both documents are marshaled with sorted keys so key order and formatting differences are not reported.
+// aName and bName are used in the diff header.
+// An empty string is returned when there are no differences.
+func UnifiedDiff(aName, bName string, a, b *YML) (string, error) {
+	aOut, err := yaml.Marshal(a.Tree)
+	if err != nil {
+		return "", err
+	}
+	bOut, err := yaml.Marshal(b.Tree)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(aName, bName, splitLines(string(aOut)), splitLines(string(bOut)), 3), nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type diffLine struct {
+	op   byte
+	text string
+	// Number of lines of each input before this one.
+	a, b int
+}
+
+// unifiedDiff returns the unified diff of the given lines with the given number of context lines.
+func unifiedDiff(aName, bName string, a, b []string, context int) string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	lines := []diffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		default:
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// Extend the hunk while the next change is close enough to share context
+		first := start - context
+		if first < 0 {
+			first = 0
+		}
+		last := start
+		for k := start; k < len(lines) && k <= last+2*context; k++ {
+			if lines[k].op != ' ' {
+				last = k
+			}
+		}
+		end := last + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		aCount, bCount := 0, 0
+		for _, l := range lines[first:end] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lines[first].a, aCount), hunkRange(lines[first].b, bCount))
+		for _, l := range lines[first:end] {
+			fmt.Fprintf(&out, "%c%s\n", l.op, l.text)
+		}
+		start = end
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
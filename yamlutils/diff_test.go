@@ -0,0 +1,172 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected []Change
+	}{
+		{"equal", "a: 1\nb: [1, 2]\n", "b: [1, 2]\na: 1\n", []Change{}},
+		{"changed scalar", "a: 1\n", "a: 2\n", []Change{
+			{ChangeChanged, []string{"a"}, 1, 2},
+		}},
+		{"string to int", "a: '1'\n", "a: 1\n", []Change{
+			{ChangeChanged, []string{"a"}, "1", 1},
+		}},
+		{"added and removed keys", "a: 1\nc: 3\n", "b: 2\nc: 3\n", []Change{
+			{ChangeRemoved, []string{"a"}, 1, nil},
+			{ChangeAdded, []string{"b"}, nil, 2},
+		}},
+		{"nested", "a:\n  b:\n    c: x\n", "a:\n  b:\n    c: z\n    d: [1]\n", []Change{
+			{ChangeChanged, []string{"a", "b", "c"}, "x", "z"},
+			{ChangeAdded, []string{"a", "b", "d"}, nil, []interface{}{1}},
+		}},
+		{"list items", "l: [1, 2, 3]\n", "l: [1, 5]\n", []Change{
+			{ChangeChanged, []string{"l", "1"}, 2, 5},
+			{ChangeRemoved, []string{"l", "2"}, 3, nil},
+		}},
+		{"list grows", "l: [1]\n", "l: [1, 2]\n", []Change{
+			{ChangeAdded, []string{"l", "1"}, nil, 2},
+		}},
+		{"type change", "a:\n  b: 1\n", "a: [1]\n", []Change{
+			{ChangeChanged, []string{"a"}, map[interface{}]interface{}{"b": 1}, []interface{}{1}},
+		}},
+		{"root", "a\n", "b\n", []Change{
+			{ChangeChanged, []string{}, "a", "b"},
+		}},
+		{"null value", "a: null\n", "a: 1\n", []Change{
+			{ChangeChanged, []string{"a"}, nil, 1},
+		}},
+		{"int and string keys", "1: a\n", "'1': a\n", []Change{
+			{ChangeRemoved, []string{"1"}, "a", nil},
+			{ChangeAdded, []string{LiteralKey("1")}, nil, "a"},
+		}},
+		{"int and string keys in both", "1: a\n'1': b\n", "1: a\n'1': c\n", []Change{
+			{ChangeChanged, []string{LiteralKey("1")}, "b", "c"},
+		}},
+		{"bool and string keys", "true: a\n", "'true': a\n", []Change{
+			{ChangeRemoved, []string{"true"}, "a", nil},
+			{ChangeAdded, []string{LiteralKey("true")}, nil, "a"},
+		}},
+		{"escaped keys", "a/b: 1\n", "a/b: 2\n", []Change{
+			{ChangeChanged, []string{"a/b"}, 1, 2},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := NewFromString(test.a)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			b, err := NewFromString(test.b)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output := Diff(a, b)
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestDiffString(t *testing.T) {
+	a, _ := NewFromString("a: 1\nb: x\nc/d: [1]\n8080: http\n")
+	b, _ := NewFromString("a: '1'\nc/d: [1, 2]\ne: {f: true}\n'8080': http\n")
+	expected := `- 8080: "http"
++ '8080': "http"
+~ a: 1 -> "1"
+- b: "x"
++ c\/d/1: 2
++ e: {"f":true}
+`
+	output := DiffString(Diff(a, b))
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	a, _ := NewFromString("a: 1\nb: x\n2: x\n")
+	b, _ := NewFromString("a: 2\nc: [1]\n'2': x\n")
+	expected := `[{"from":"x","path":"2","type":"removed"},{"path":"'2'","to":"x","type":"added"},{"from":1,"path":"a","to":2,"type":"changed"},{"from":"x","path":"b","type":"removed"},{"path":"c","to":[1],"type":"added"}]
+`
+	output, err := DiffJSON(Diff(a, b), FormatJSON)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+	output, err = DiffJSON([]Change{}, FormatJSON)
+	if err != nil || output != "[]\n" {
+		t.Errorf("Unexpected output: %s, %v\n", output, err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{"equal", "b: 2\na: 1\n", "a: 1\nb: 2\n", ""},
+		{"changed", "a: 1\nb: 2\nc: 3\n", "c: 3\nb: 5\na: 1\n", `--- a.yml
++++ b.yml
+@@ -1,3 +1,3 @@
+ a: 1
+-b: 2
++b: 5
+ c: 3
+`},
+		{"separate hunks", "a: 1\nb: 2\nc: 3\nd: 4\ne: 5\nf: 6\ng: 7\nh: 8\ni: 9\nj: 10\n",
+			"a: 0\nb: 2\nc: 3\nd: 4\ne: 5\nf: 6\ng: 7\nh: 8\ni: 9\n", `--- a.yml
++++ b.yml
+@@ -1,4 +1,4 @@
+-a: 1
++a: 0
+ b: 2
+ c: 3
+ d: 4
+@@ -7,4 +7,3 @@
+ g: 7
+ h: 8
+ i: 9
+-j: 10
+`},
+		{"added to empty", "{}\n", "a: 1\n", `--- a.yml
++++ b.yml
+@@ -1 +1 @@
+-{}
++a: 1
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, _ := NewFromString(test.a)
+			b, _ := NewFromString(test.b)
+			output, err := UnifiedDiff("a.yml", "b.yml", a, b)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
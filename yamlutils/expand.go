@@ -0,0 +1,243 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrUndefinedVariable - The environment variable is not set and has no default.
+var ErrUndefinedVariable = fmt.Errorf("undefined variable")
+
+// ErrExpandCycle - A reference points back to itself.
+var ErrExpandCycle = fmt.Errorf("reference cycle")
+
+// ErrInvalidExpansion - The placeholder is not valid.
+var ErrInvalidExpansion = fmt.Errorf("invalid expansion")
+
+// ExpandOptions - Options for Expand.
+type ExpandOptions struct {
+	// LookupEnv - Function used to look up environment variables, os.LookupEnv when nil.
+	LookupEnv func(key string) (string, bool)
+	// ReadFile - Function used to read ${file:...} placeholders, ioutil.ReadFile when nil.
+	ReadFile func(filename string) ([]byte, error)
+}
+
+// Expand resolves the placeholders in the string values of the Tree.
+// See ExpandTree for the supported placeholders.
+// The Tree is only modified when all the placeholders could be expanded.
+func (y *YML) Expand(opts ExpandOptions) error {
+	tree, err := ExpandTree(y.Tree, opts)
+	if err != nil {
+		return err
+	}
+	y.Tree = tree
+	return nil
+}
+
+// ExpandTree returns a copy of the tree with the placeholders in string values resolved:
+//
+//	${VAR}              Environment variable, it is an error if it is not set.
+//	${VAR:-default}     Environment variable, default is used when it is not set or empty.
+//	${file:/path}       Contents of the file, without the trailing newline.
+//	${ref:path/to/key}  Value of another element of the document, using the SplitPath syntax.
+//	$${                 Literal '${'.
+//
+// Defaults can contain other placeholders, for example: ${HOST:-${ref:defaults/host}}.
+// When a value is a single ${ref:...} placeholder, the referenced element is used as is so maps, lists and numbers
+// keep their type, otherwise it must be a scalar and it is converted to a string.
+// References are expanded before use and cycles return ErrExpandCycle.
+// Map keys are not expanded.
+func ExpandTree(tree interface{}, opts ExpandOptions) (interface{}, error) {
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+	if opts.ReadFile == nil {
+		opts.ReadFile = ioutil.ReadFile
+	}
+	e := &expander{
+		opts:       opts,
+		root:       tree,
+		done:       map[string]interface{}{},
+		inProgress: map[string]bool{},
+	}
+	return e.expand(tree, []string{})
+}
+
+type expander struct {
+	opts ExpandOptions
+	root interface{}
+	// Expanded values by path
+	done       map[string]interface{}
+	inProgress map[string]bool
+}
+
+func (e *expander) expand(m interface{}, path []string) (interface{}, error) {
+	key := JoinPath(path)
+	if v, ok := e.done[key]; ok {
+		return v, nil
+	}
+	if e.inProgress[key] {
+		return nil, fmt.Errorf("%w: %s", ErrExpandCycle, key)
+	}
+	e.inProgress[key] = true
+	defer delete(e.inProgress, key)
+
+	var r interface{}
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		rm := make(map[interface{}]interface{}, len(t))
+		for k, v := range t {
+			ev, err := e.expand(v, appendPath(path, pathKey(k)))
+			if err != nil {
+				return nil, err
+			}
+			rm[k] = ev
+		}
+		r = rm
+	case []interface{}:
+		rl := make([]interface{}, len(t))
+		for i, v := range t {
+			ev, err := e.expand(v, appendPath(path, fmt.Sprint(i)))
+			if err != nil {
+				return nil, err
+			}
+			rl[i] = ev
+		}
+		r = rl
+	case string:
+		var err error
+		r, err = e.expandString(t, path)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		r = m
+	}
+	e.done[key] = r
+	return r, nil
+}
+
+// expandString returns the expanded string, or the referenced element when s is a single ${ref:...} placeholder.
+func (e *expander) expandString(s string, path []string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	if strings.HasPrefix(s, "${ref:") {
+		if end := placeholderEnd(s, 0); end == len(s) {
+			return e.ref(s[len("${ref:"):end-1], path)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += len("$${")
+		case strings.HasPrefix(s[i:], "${"):
+			end := placeholderEnd(s, i)
+			if end == -1 {
+				return nil, fmt.Errorf("yaml path '%s': %w: missing closing brace: %s", JoinPath(path), ErrInvalidExpansion, s[i:])
+			}
+			v, err := e.placeholder(s[i+2:end-1], path)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(v)
+			i = end
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// placeholderEnd returns the index after the closing brace of the placeholder starting at i, or -1.
+func placeholderEnd(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch {
+		case strings.HasPrefix(s[j:], "${"):
+			depth++
+			j++
+		case s[j] == '}':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
+
+var variableRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholder returns the string value of the placeholder body, the text between '${' and '}'.
+func (e *expander) placeholder(body string, path []string) (string, error) {
+	switch {
+	case strings.HasPrefix(body, "ref:"):
+		v, err := e.ref(body[len("ref:"):], path)
+		if err != nil {
+			return "", err
+		}
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return "", fmt.Errorf("yaml path '%s': %w: ${%s} must reference a scalar when used inside a string",
+				JoinPath(path), ErrInvalidExpansion, body)
+		}
+		return KeyString(v), nil
+	case strings.HasPrefix(body, "file:"):
+		filename := body[len("file:"):]
+		data, err := e.opts.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("yaml path '%s': %w", JoinPath(path), err)
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+	}
+	name, def, hasDefault := body, "", false
+	if i := strings.Index(body, ":-"); i != -1 {
+		name, def, hasDefault = body[:i], body[i+2:], true
+	}
+	if !variableRegex.MatchString(name) {
+		return "", fmt.Errorf("yaml path '%s': %w: ${%s}", JoinPath(path), ErrInvalidExpansion, body)
+	}
+	value, ok := e.opts.LookupEnv(name)
+	if ok && (value != "" || !hasDefault) {
+		return value, nil
+	}
+	if !hasDefault {
+		return "", fmt.Errorf("yaml path '%s': %w: %s", JoinPath(path), ErrUndefinedVariable, name)
+	}
+	v, err := e.expandString(def, path)
+	if err != nil {
+		return "", err
+	}
+	return KeyString(v), nil
+}
+
+// ref returns the expanded element at the referenced path.
+func (e *expander) ref(ref string, path []string) (interface{}, error) {
+	p := SplitPath(ref)
+	Logger.Printf("expand: '%s' references '%s'", JoinPath(path), ref)
+	target, _, err := NavigateTree(false, e.root, p)
+	if err != nil {
+		return nil, fmt.Errorf("yaml path '%s': reference '%s': %w", JoinPath(path), ref, err)
+	}
+	v, err := e.expand(target, p)
+	if err != nil {
+		return nil, fmt.Errorf("yaml path '%s': reference '%s': %w", JoinPath(path), ref, err)
+	}
+	// The referenced element can end up in several places of the tree, they must not share maps or lists
+	return deepCopy(v), nil
+}
@@ -0,0 +1,115 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExpandTree(t *testing.T) {
+	env := map[string]string{"HOST": "db.local", "EMPTY": "", "PORT": "5432"}
+	files := map[string]string{"/run/secrets/pw": "s3cret\n"}
+	opts := ExpandOptions{
+		LookupEnv: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+		ReadFile: func(filename string) ([]byte, error) {
+			v, ok := files[filename]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return []byte(v), nil
+		},
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+		err      error
+	}{
+		{"no placeholders", "a: b\nnum: 1\n", map[interface{}]interface{}{"a": "b", "num": 1}, nil},
+		{"env", "a: ${HOST}\n", map[interface{}]interface{}{"a": "db.local"}, nil},
+		{"env inside string", "a: postgres://${HOST}:${PORT}/db\n", map[interface{}]interface{}{"a": "postgres://db.local:5432/db"}, nil},
+		{"env keeps string type", "a: ${PORT}\n", map[interface{}]interface{}{"a": "5432"}, nil},
+		{"default unset", "a: ${MISSING:-x}\n", map[interface{}]interface{}{"a": "x"}, nil},
+		{"default empty", "a: ${EMPTY:-x}\n", map[interface{}]interface{}{"a": "x"}, nil},
+		{"empty no default", "a: '${EMPTY}'\n", map[interface{}]interface{}{"a": ""}, nil},
+		{"nested default", "a: ${MISSING:-${HOST}}\n", map[interface{}]interface{}{"a": "db.local"}, nil},
+		{"undefined", "a: ${MISSING}\n", nil, ErrUndefinedVariable},
+		{"invalid name", "a: ${1A}\n", nil, ErrInvalidExpansion},
+		{"missing brace", "a: ${HOST\n", nil, ErrInvalidExpansion},
+		{"escaped", "a: $${HOST} ${HOST}\n", map[interface{}]interface{}{"a": "${HOST} db.local"}, nil},
+		{"file", "a: ${file:/run/secrets/pw}\n", map[interface{}]interface{}{"a": "s3cret"}, nil},
+		{"missing file", "a: ${file:/missing}\n", nil, os.ErrNotExist},
+		{"ref scalar", "a: ${ref:b/c}\nb: {c: 1}\n", map[interface{}]interface{}{"a": 1, "b": map[interface{}]interface{}{"c": 1}}, nil},
+		{"ref map", "a: ${ref:b}\nb: {c: 1}\n",
+			map[interface{}]interface{}{"a": map[interface{}]interface{}{"c": 1}, "b": map[interface{}]interface{}{"c": 1}}, nil},
+		{"ref inside string", "a: port ${ref:b/0}\nb: [80]\n", map[interface{}]interface{}{"a": "port 80", "b": []interface{}{80}}, nil},
+		{"ref map inside string", "a: x ${ref:b}\nb: {c: 1}\n", nil, ErrInvalidExpansion},
+		{"ref chain", "a: ${ref:b}\nb: ${ref:c}\nc: ${HOST}\n",
+			map[interface{}]interface{}{"a": "db.local", "b": "db.local", "c": "db.local"}, nil},
+		{"ref escaped key", "a: ${ref:b\\/c}\nb/c: 1\n", map[interface{}]interface{}{"a": 1, "b/c": 1}, nil},
+		{"ref missing", "a: ${ref:b}\n", nil, ErrMapKeyNotFound},
+		{"ref cycle", "a: ${ref:b}\nb: ${ref:a}\n", nil, ErrExpandCycle},
+		{"ref self", "a: x ${ref:a}\n", nil, ErrExpandCycle},
+		{"ref parent", "a:\n  b: ${ref:a}\n", nil, ErrExpandCycle},
+		{"default ref", "a: ${MISSING:-${ref:b}}\nb: z\n", map[interface{}]interface{}{"a": "z", "b": "z"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y, err := NewFromString(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			err = y.Expand(opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if test.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(y.Tree, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, y.Tree)
+			}
+		})
+	}
+}
+
+func TestExpandAtomic(t *testing.T) {
+	y, _ := NewFromString("a: ${HOST}\nb: ${UNDEFINED_VARIABLE_FOR_TEST}\n")
+	err := y.Expand(ExpandOptions{LookupEnv: func(key string) (string, bool) {
+		if key == "HOST" {
+			return "h", true
+		}
+		return "", false
+	}})
+	if !errors.Is(err, ErrUndefinedVariable) {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := map[interface{}]interface{}{"a": "${HOST}", "b": "${UNDEFINED_VARIABLE_FOR_TEST}"}
+	if !reflect.DeepEqual(y.Tree, expected) {
+		t.Errorf("Tree was modified:\n%#v\n", y.Tree)
+	}
+}
+
+func TestExpandRefCopies(t *testing.T) {
+	y, _ := NewFromString("a: ${ref:b}\nb: {c: 1}\n")
+	err := y.Expand(ExpandOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	y.Tree.(map[interface{}]interface{})["a"].(map[interface{}]interface{})["c"] = 2
+	if y.Tree.(map[interface{}]interface{})["b"].(map[interface{}]interface{})["c"] != 1 {
+		t.Errorf("Referenced element was modified\n")
+	}
+}
@@ -0,0 +1,227 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Shell - Shell syntax used by Export.
+type Shell string
+
+const (
+	// ShellBash - bash and zsh assignments, lists are arrays.
+	ShellBash Shell = "bash"
+	// ShellSh - POSIX shell assignments, lists of values are an error as sh has no arrays.
+	ShellSh Shell = "sh"
+	// ShellFish - fish 'set' commands.
+	ShellFish Shell = "fish"
+)
+
+// ErrInvalidShell - The given shell is not supported.
+var ErrInvalidShell = fmt.Errorf("invalid shell")
+
+// ErrInvalidName - The element can't be exported with a valid and unique variable name.
+var ErrInvalidName = fmt.Errorf("invalid variable name")
+
+// ParseShell returns the Shell for the given name.
+// Valid names are: bash, sh, zsh and fish.
+func ParseShell(name string) (Shell, error) {
+	switch strings.ToLower(name) {
+	case "", "bash", "zsh":
+		return ShellBash, nil
+	case "sh":
+		return ShellSh, nil
+	case "fish":
+		return ShellFish, nil
+	}
+	return ShellBash, fmt.Errorf("%w: %s", ErrInvalidShell, name)
+}
+
+// ExportOptions - Options for Export.
+type ExportOptions struct {
+	// Prefix - Prefix of every variable name, joined to the name with '_'.
+	Prefix string
+	// Shell - Syntax of the assignments, ShellBash when empty.
+	Shell Shell
+}
+
+// Export returns one shell assignment for every scalar of the tree, for example:
+//
+//	APP_DB_HOST='localhost'
+//	APP_HOSTS=('a' 'b')
+//	APP_SERVERS_0_NAME='api'
+//
+// Variable names are the prefix and the path elements joined with '_', converted to upper case and with every
+// character other than letters, digits and '_' replaced by '_'.
+// Lists of scalars are exported as arrays, other lists use the item index as part of the name.
+// ShellSh has no arrays and returns ErrUnsupportedValue for lists of scalars.
+// Values are single quoted, null is an empty string and empty maps are skipped.
+// Two elements with the same variable name return ErrInvalidName.
+func Export(tree interface{}, opts ExportOptions) ([]string, error) {
+	if opts.Shell == "" {
+		opts.Shell = ShellBash
+	}
+	if opts.Shell != ShellBash && opts.Shell != ShellSh && opts.Shell != ShellFish {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidShell, opts.Shell)
+	}
+	e := &exporter{shell: opts.Shell, names: map[string]string{}}
+	prefix := strings.TrimSuffix(sanitizeName(opts.Prefix), "_")
+	err := e.export(tree, prefix, []string{})
+	return e.lines, err
+}
+
+// Export returns the assignments for the element matched by the path, see Export.
+// Variable names are built from the path relative to the matched element.
+func (y *YML) Export(keys []string, opts ExportOptions) (string, error) {
+	matches, err := FindAll(y.Tree, keys)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("%w: %s", ErrMultipleMatches, JoinPath(keys))
+	}
+	lines, err := Export(matches[0].Value, opts)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// VariableName returns s converted to upper case with every character other than letters, digits and '_'
+// replaced by '_'.
+// Names starting with a digit are prefixed with '_'.
+func VariableName(s string) string {
+	name := sanitizeName(s)
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+type exporter struct {
+	shell Shell
+	lines []string
+	// Path of the element exported with each name
+	names map[string]string
+}
+
+func (e *exporter) export(m interface{}, name string, path []string) error {
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(t))
+		values := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			keys = append(keys, KeyString(k))
+			values[KeyString(k)] = v
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			err := e.export(values[k], joinName(name, k), appendPath(path, k))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if !scalarList(t) {
+			for i, v := range t {
+				err := e.export(v, joinName(name, strconv.Itoa(i)), appendPath(path, strconv.Itoa(i)))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if e.shell == ShellSh {
+			return fmt.Errorf("%w: yaml path '%s': sh has no arrays for lists", ErrUnsupportedValue, JoinPath(path))
+		}
+		name, err := e.addName(name, path)
+		if err != nil {
+			return err
+		}
+		values := make([]string, len(t))
+		for i, v := range t {
+			values[i] = e.quote(scalarValue(v))
+		}
+		if e.shell == ShellFish {
+			e.lines = append(e.lines, strings.TrimSpace("set "+name+" "+strings.Join(values, " ")))
+		} else {
+			e.lines = append(e.lines, name+"=("+strings.Join(values, " ")+")")
+		}
+		return nil
+	}
+	name, err := e.addName(name, path)
+	if err != nil {
+		return err
+	}
+	if e.shell == ShellFish {
+		e.lines = append(e.lines, "set "+name+" "+e.quote(scalarValue(m)))
+	} else {
+		e.lines = append(e.lines, name+"="+e.quote(scalarValue(m)))
+	}
+	return nil
+}
+
+// addName returns the variable name for the element at path, checking that it is unique.
+func (e *exporter) addName(name string, path []string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: a prefix is required to export a single value", ErrInvalidName)
+	}
+	name = VariableName(name)
+	if other, ok := e.names[name]; ok {
+		return "", fmt.Errorf("%w: yaml paths '%s' and '%s' are both exported as %s", ErrInvalidName, other, JoinPath(path), name)
+	}
+	e.names[name] = JoinPath(path)
+	return name, nil
+}
+
+// quote returns s in single quotes escaped for the shell.
+func (e *exporter) quote(s string) string {
+	if e.shell == ShellFish {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func joinName(name, e string) string {
+	if name == "" {
+		return sanitizeName(e)
+	}
+	return name + "_" + sanitizeName(e)
+}
+
+// scalarList returns true when no item of the list is a map or a list.
+func scalarList(l []interface{}) bool {
+	for _, v := range l {
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"testing"
+)
+
+var exportDoc = `
+db:
+  host: localhost
+  port: 5432
+  password: "it's $secret \\ \"x\""
+  user: null
+hosts: [a, b c]
+empty_list: []
+empty_map: {}
+servers:
+  - name: api
+    ports: [80]
+  - name: web
+app.kubernetes.io/name: api
+2fa: true
+`
+
+func TestExport(t *testing.T) {
+	y, _ := NewFromString(exportDoc)
+	tests := []struct {
+		name     string
+		path     string
+		opts     ExportOptions
+		expected string
+		err      error
+	}{
+		{"bash", "", ExportOptions{Prefix: "app"}, `APP_2FA='true'
+APP_APP_KUBERNETES_IO_NAME='api'
+APP_DB_HOST='localhost'
+APP_DB_PASSWORD='it'\''s $secret \ "x"'
+APP_DB_PORT='5432'
+APP_DB_USER=''
+APP_EMPTY_LIST=()
+APP_HOSTS=('a' 'b c')
+APP_SERVERS_0_NAME='api'
+APP_SERVERS_0_PORTS=('80')
+APP_SERVERS_1_NAME='web'
+`, nil},
+		{"fish", "", ExportOptions{Prefix: "APP_", Shell: ShellFish}, `set APP_2FA 'true'
+set APP_APP_KUBERNETES_IO_NAME 'api'
+set APP_DB_HOST 'localhost'
+set APP_DB_PASSWORD 'it\'s $secret \\ "x"'
+set APP_DB_PORT '5432'
+set APP_DB_USER ''
+set APP_EMPTY_LIST
+set APP_HOSTS 'a' 'b c'
+set APP_SERVERS_0_NAME 'api'
+set APP_SERVERS_0_PORTS '80'
+set APP_SERVERS_1_NAME 'web'
+`, nil},
+		{"sh", "db", ExportOptions{Prefix: "db", Shell: ShellSh}, `DB_HOST='localhost'
+DB_PASSWORD='it'\''s $secret \ "x"'
+DB_PORT='5432'
+DB_USER=''
+`, nil},
+		{"sh list", "", ExportOptions{Prefix: "app", Shell: ShellSh}, "", ErrUnsupportedValue},
+		{"subtree", "servers/[name=api]", ExportOptions{Prefix: "api-server"}, `API_SERVER_NAME='api'
+API_SERVER_PORTS=('80')
+`, nil},
+		{"no prefix", "db", ExportOptions{}, `HOST='localhost'
+PASSWORD='it'\''s $secret \ "x"'
+PORT='5432'
+USER=''
+`, nil},
+		{"scalar", "db/host", ExportOptions{Prefix: "host"}, "HOST='localhost'\n", nil},
+		{"scalar without prefix", "db/host", ExportOptions{}, "", ErrInvalidName},
+		{"multiple matches", "servers/*", ExportOptions{Prefix: "x"}, "", ErrMultipleMatches},
+		{"invalid shell", "db", ExportOptions{Shell: "csh"}, "", ErrInvalidShell},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := y.Export(SplitPath(test.path), test.opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestExportNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		prefix   string
+		expected string
+		err      error
+	}{
+		{"{2fa: true}", "", "_2FA='true'\n", nil},
+		{"{2fa: true}", "app", "APP_2FA='true'\n", nil},
+		{"{a: {2: x}}", "", "A_2='x'\n", nil},
+		{"{db-host: a, db_host: b}", "x", "", ErrInvalidName},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			y, _ := NewFromString(test.input)
+			output, err := y.Export([]string{}, ExportOptions{Prefix: test.prefix})
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestVariableName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"host", "HOST"},
+		{"db.host-name", "DB_HOST_NAME"},
+		{"2fa", "_2FA"},
+		{"_private", "_PRIVATE"},
+		{"café", "CAF_"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			output := VariableName(test.input)
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestParseShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Shell
+		err      error
+	}{
+		{"", ShellBash, nil},
+		{"zsh", ShellBash, nil},
+		{"sh", ShellSh, nil},
+		{"FISH", ShellFish, nil},
+		{"csh", ShellBash, ErrInvalidShell},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := ParseShell(test.name)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
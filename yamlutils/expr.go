@@ -0,0 +1,1826 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrExprSyntax - The expression is not valid.
+var ErrExprSyntax = fmt.Errorf("expression syntax error")
+
+// ErrExprEval - The expression can't be evaluated against its input.
+var ErrExprEval = fmt.Errorf("expression error")
+
+// Expr - Compiled expression, see CompileExpr for the syntax.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// CompileExpr parses a jq-like expression.
+// An expression is a filter that receives an input element and produces zero or more outputs:
+//
+//	.                       The input.
+//	.name, ."name", .[e]    Map value or list item, null when missing. Negative indexes count from the end.
+//	.[start:end]            List or string slice.
+//	.[]                     Every list item or map value, map values in key order.
+//	..                      The input and all its descendants.
+//	e?                      Outputs of e, errors are ignored.
+//	a | b                   Outputs of b for each output of a.
+//	a, b                    Outputs of a followed by the outputs of b.
+//	a // b                  Outputs of a that are not false or null, outputs of b when there are none.
+//	a or b, a and b         Boolean operators, only false and null are false.
+//	==, !=, <, <=, >, >=    Comparisons, ordering is null < false < true < numbers < strings < lists < maps.
+//	+, -, *, /, %           Arithmetic, + also joins strings and lists and merges maps, - removes list items.
+//	[e], {key: e, name}     List and map construction, {name} is {name: .name} and keys can be "strings" or (e).
+//	"text \(e)"             String interpolation.
+//	if c then a elif c2 then b else d end
+//
+// Functions:
+//
+//	length, keys, has(k), select(f), map(f), not, type, empty, sort, add, first, last, reverse,
+//	join(sep), startswith(s), endswith(s), contains(x), test(regex), split(sep), tostring, tonumber,
+//	to_entries, from_entries
+//
+// For example:
+//
+//	.servers[] | select(.port > 1024 and has("tls")) | .name
+//	.services | map({name, replicas: (.replicas // 1)})
+func CompileExpr(src string) (*Expr, error) {
+	p := &exprParser{src: src}
+	err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
+	root, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval returns the outputs of the expression for the given input.
+func (e *Expr) Eval(input interface{}) ([]interface{}, error) {
+	return e.root.eval(input)
+}
+
+// EvalExpr compiles and evaluates the expression against the tree, see CompileExpr.
+func EvalExpr(tree interface{}, expr string) ([]interface{}, error) {
+	e, err := CompileExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	Logger.Printf("expr: evaluating '%s'", expr)
+	return e.Eval(tree)
+}
+
+// Eval returns the outputs of the expression evaluated against the Tree, see CompileExpr.
+func (y *YML) Eval(expr string) ([]interface{}, error) {
+	return EvalExpr(y.Tree, expr)
+}
+
+// EvalString returns the outputs of the expression in the YML output format.
+// With YAML output, scalars are printed one per line and maps and lists as YAML, several outputs with maps or
+// lists are returned as a multi-document stream.
+// With JSON output, each output is printed in its own line, or indented with FormatJSONPretty.
+func (y *YML) EvalString(expr string) (string, error) {
+	results, err := y.Eval(expr)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if y.jsonOutput() {
+		for _, r := range results {
+			out, err := y.marshal(r)
+			if err != nil {
+				return b.String(), fmt.Errorf("failed to Marshal output: %w", err)
+			}
+			b.Write(out)
+		}
+		return b.String(), nil
+	}
+	docs := []string{}
+	scalars := true
+	for _, r := range results {
+		switch r.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			scalars = false
+			out, err := y.marshal(r)
+			if err != nil {
+				return b.String(), fmt.Errorf("failed to Marshal output: %w", err)
+			}
+			docs = append(docs, string(out))
+		default:
+			docs = append(docs, KeyString(r)+"\n")
+		}
+	}
+	if scalars || len(docs) == 1 {
+		return strings.Join(docs, ""), nil
+	}
+	return JoinDocuments(docs), nil
+}
+
+//
+// Lexer
+//
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokOp
+	tokDot
+	tokDotDot
+	tokField
+	tokIdent
+	tokNumber
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	// Operator, field or identifier name, or number text
+	text  string
+	pos   int
+	parts []stringPart
+}
+
+// stringPart - Literal text or interpolated expression of a string token.
+type stringPart struct {
+	text string
+	expr exprNode
+}
+
+type exprParser struct {
+	src    string
+	offset int
+	tokens []token
+	i      int
+}
+
+var exprOperators = []string{"//", "==", "!=", "<=", ">=", "|", ",", "<", ">", "+", "-", "*", "/", "%", "(", ")",
+	"[", "]", "{", "}", ":", ";", "?"}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func (p *exprParser) errorf(pos int, format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s at position %d", ErrExprSyntax, fmt.Sprintf(format, a...), p.offset+pos+1)
+}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], ".."):
+			p.tokens = append(p.tokens, token{kind: tokDotDot, text: "..", pos: i})
+			i += 2
+		case c == '.' && i+1 < len(s) && isIdentStart(s[i+1]):
+			j := i + 1
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokField, text: s[i+1 : j], pos: i})
+			i = j
+		case c == '.' && (i+1 == len(s) || s[i+1] < '0' || s[i+1] > '9'):
+			p.tokens = append(p.tokens, token{kind: tokDot, text: ".", pos: i})
+			i++
+		case (c >= '0' && c <= '9') || c == '.':
+			j := i
+			for j < len(s) && ((s[j] >= '0' && s[j] <= '9') || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				j++
+				if j < len(s) && (s[j] == '+' || s[j] == '-') {
+					j++
+				}
+				for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+					j++
+				}
+			}
+			p.tokens = append(p.tokens, token{kind: tokNumber, text: s[i:j], pos: i})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokIdent, text: s[i:j], pos: i})
+			i = j
+		case c == '"':
+			parts, end, err := p.lexString(i)
+			if err != nil {
+				return err
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, pos: i, parts: parts})
+			i = end
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(s[i:], op) {
+					p.tokens = append(p.tokens, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return p.errorf(i, "unexpected character '%c'", c)
+			}
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokEOF, pos: len(s)})
+	return nil
+}
+
+// lexString returns the parts of the string starting at the quote in position start and the position after its
+// closing quote.
+func (p *exprParser) lexString(start int) ([]stringPart, int, error) {
+	s := p.src
+	parts := []stringPart{}
+	var b strings.Builder
+	for i := start + 1; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			if b.Len() > 0 || len(parts) == 0 {
+				parts = append(parts, stringPart{text: b.String()})
+			}
+			return parts, i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case '"', '\\', '/':
+				b.WriteByte(s[i])
+			case 'u':
+				if i+5 > len(s) {
+					return nil, 0, p.errorf(i, "invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+				if err != nil {
+					return nil, 0, p.errorf(i, "invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			case '(':
+				end, err := p.interpolationEnd(i)
+				if err != nil {
+					return nil, 0, err
+				}
+				sub := &exprParser{src: s[i+1 : end], offset: p.offset + i + 1}
+				err = sub.tokenize()
+				if err != nil {
+					return nil, 0, err
+				}
+				node, err := sub.parsePipe()
+				if err != nil {
+					return nil, 0, err
+				}
+				if t := sub.peek(); t.kind != tokEOF {
+					return nil, 0, sub.unexpected(t)
+				}
+				if b.Len() > 0 {
+					parts = append(parts, stringPart{text: b.String()})
+					b.Reset()
+				}
+				parts = append(parts, stringPart{expr: node})
+				i = end
+			default:
+				return nil, 0, p.errorf(i, "invalid escape '\\%c'", s[i])
+			}
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return nil, 0, p.errorf(start, "missing closing quote")
+}
+
+// interpolationEnd returns the position of the parenthesis closing the interpolation that starts at start.
+func (p *exprParser) interpolationEnd(start int) (int, error) {
+	s := p.src
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		case '"':
+			_, end, err := p.lexString(i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+	return 0, p.errorf(start, "missing closing parenthesis in string interpolation")
+}
+
+//
+// Parser
+//
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == text
+}
+
+func (p *exprParser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == text
+}
+
+func (p *exprParser) expectOp(text string) error {
+	t := p.next()
+	if t.kind != tokOp || t.text != text {
+		return p.errorf(t.pos, "expected '%s', found %s", text, describeToken(t))
+	}
+	return nil
+}
+
+func (p *exprParser) expectKeyword(text string) error {
+	t := p.next()
+	if t.kind != tokIdent || t.text != text {
+		return p.errorf(t.pos, "expected '%s', found %s", text, describeToken(t))
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected(t token) error {
+	return p.errorf(t.pos, "unexpected %s", describeToken(t))
+}
+
+func describeToken(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return "string"
+	case tokField:
+		return "'." + t.text + "'"
+	}
+	return "'" + t.text + "'"
+}
+
+// parsePipe parses: comma ('|' comma)*
+func (p *exprParser) parsePipe() (exprNode, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("|") {
+		p.next()
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = &pipeNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseComma parses: alt (',' alt)*
+func (p *exprParser) parseComma() (exprNode, error) {
+	left, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(",") {
+		p.next()
+		right, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		left = &commaNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAlt parses: or ('//' alt)?
+func (p *exprParser) parseAlt() (exprNode, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("//") {
+		p.next()
+		right, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		return &altNode{left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// parseOr parses: and ('or' and)*
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: comparison ('and' comparison)*
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseComparison parses: additive (op additive)?, comparisons don't chain.
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.isOp(op) {
+			p.next()
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parseAdditive parses: multiplicative (('+'|'-') multiplicative)*
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseMultiplicative parses: unary (('*'|'/'|'%') unary)*
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses: '-' unary | postfix
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: "-", left: &literalNode{value: 0}, right: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses: primary suffix*
+// suffix: .name | ."name" | [] | [e] | [e:e] | ?
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokField:
+			p.next()
+			node = &indexNode{target: node, index: &literalNode{value: t.text}}
+		case t.kind == tokDot && p.tokens[p.i+1].kind == tokString:
+			p.next()
+			key, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			node = &indexNode{target: node, index: key}
+		case t.kind == tokDot && p.tokens[p.i+1].kind == tokOp && p.tokens[p.i+1].text == "[":
+			p.next()
+		case p.isOp("["):
+			node, err = p.parseBracketSuffix(node)
+			if err != nil {
+				return nil, err
+			}
+		case p.isOp("?"):
+			p.next()
+			node = &tryNode{body: node}
+		default:
+			return node, nil
+		}
+	}
+}
+
+// parseBracketSuffix parses: '[' ']' | '[' e ']' | '[' e? ':' e? ']'
+func (p *exprParser) parseBracketSuffix(target exprNode) (exprNode, error) {
+	p.next()
+	if p.isOp("]") {
+		p.next()
+		return &iterateNode{target: target}, nil
+	}
+	var from, to exprNode
+	var err error
+	if !p.isOp(":") {
+		from, err = p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if p.isOp("]") {
+			p.next()
+			return &indexNode{target: target, index: from}, nil
+		}
+	}
+	err = p.expectOp(":")
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("]") {
+		to, err = p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = p.expectOp("]")
+	if err != nil {
+		return nil, err
+	}
+	return &sliceNode{target: target, from: from, to: to}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokDot:
+		if p.peek().kind == tokString {
+			key, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return &indexNode{target: identityNode{}, index: key}, nil
+		}
+		return identityNode{}, nil
+	case tokDotDot:
+		return recurseNode{}, nil
+	case tokField:
+		return &indexNode{target: identityNode{}, index: &literalNode{value: t.text}}, nil
+	case tokNumber:
+		if i, err := strconv.Atoi(t.text); err == nil {
+			return &literalNode{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t.pos, "invalid number '%s'", t.text)
+		}
+		return &literalNode{value: f}, nil
+	case tokString:
+		if len(t.parts) == 1 && t.parts[0].expr == nil {
+			return &literalNode{value: t.parts[0].text}, nil
+		}
+		return &stringNode{parts: t.parts}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "if":
+			return p.parseIf()
+		case "then", "elif", "else", "end", "and", "or":
+			return nil, p.unexpected(t)
+		}
+		return p.parseCall(t)
+	case tokOp:
+		switch t.text {
+		case "(":
+			node, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expectOp(")")
+		case "[":
+			if p.isOp("]") {
+				p.next()
+				return &arrayNode{}, nil
+			}
+			body, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			return &arrayNode{body: body}, p.expectOp("]")
+		case "{":
+			return p.parseObject()
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// parseIf parses: 'if' e 'then' e ('elif' e 'then' e)* ('else' e)? 'end'
+func (p *exprParser) parseIf() (exprNode, error) {
+	cond, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	err = p.expectKeyword("then")
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	node := &ifNode{cond: cond, then: then}
+	switch {
+	case p.isKeyword("elif"):
+		p.next()
+		node.otherwise, err = p.parseIf()
+		return node, err
+	case p.isKeyword("else"):
+		p.next()
+		node.otherwise, err = p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, p.expectKeyword("end")
+}
+
+// parseObject parses: '{' (entry (',' entry)*)? '}'
+// entry: (ident | string | '(' e ')') (':' alt)?
+func (p *exprParser) parseObject() (exprNode, error) {
+	node := &objectNode{}
+	if p.isOp("}") {
+		p.next()
+		return node, nil
+	}
+	for {
+		var key exprNode
+		var name string
+		t := p.next()
+		switch {
+		case t.kind == tokIdent:
+			name = t.text
+			key = &literalNode{value: t.text}
+		case t.kind == tokString:
+			if len(t.parts) == 1 && t.parts[0].expr == nil {
+				name = t.parts[0].text
+				key = &literalNode{value: name}
+			} else {
+				key = &stringNode{parts: t.parts}
+			}
+		case t.kind == tokOp && t.text == "(":
+			var err error
+			key, err = p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expectOp(")")
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.errorf(t.pos, "expected map key, found %s", describeToken(t))
+		}
+		var value exprNode
+		if p.isOp(":") {
+			p.next()
+			var err error
+			value, err = p.parseAlt()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if name == "" && t.kind != tokIdent {
+				return nil, p.errorf(t.pos, "expected ':' after computed map key")
+			}
+			// {name} is {name: .name}
+			value = &indexNode{target: identityNode{}, index: &literalNode{value: name}}
+		}
+		node.keys = append(node.keys, key)
+		node.values = append(node.values, value)
+		if p.isOp("}") {
+			p.next()
+			return node, nil
+		}
+		err := p.expectOp(",")
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// exprFunctions - Number of arguments of each function.
+var exprFunctions = map[string]int{
+	"length": 0, "keys": 0, "not": 0, "type": 0, "empty": 0, "sort": 0, "add": 0, "first": 0, "last": 0,
+	"reverse": 0, "tostring": 0, "tonumber": 0, "to_entries": 0, "from_entries": 0,
+	"has": 1, "select": 1, "map": 1, "join": 1, "startswith": 1, "endswith": 1, "contains": 1, "test": 1,
+	"split": 1,
+}
+
+// parseCall parses: ident ('(' pipe (';' pipe)* ')')?
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	node := &callNode{name: name.text}
+	if p.isOp("(") {
+		p.next()
+		for {
+			arg, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			node.args = append(node.args, arg)
+			if !p.isOp(";") {
+				break
+			}
+			p.next()
+		}
+		err := p.expectOp(")")
+		if err != nil {
+			return nil, err
+		}
+	}
+	n, ok := exprFunctions[node.name]
+	if !ok || n != len(node.args) {
+		return nil, p.errorf(name.pos, "unknown function '%s/%d'", node.name, len(node.args))
+	}
+	if node.name == "test" {
+		if lit, ok := node.args[0].(*literalNode); ok {
+			if re, ok := lit.value.(string); ok {
+				var err error
+				node.re, err = regexp.Compile(re)
+				if err != nil {
+					return nil, p.errorf(name.pos, "invalid regex: %s", err)
+				}
+			}
+		}
+	}
+	return node, nil
+}
+
+//
+// Evaluation
+//
+
+type exprNode interface {
+	eval(input interface{}) ([]interface{}, error)
+}
+
+type identityNode struct{}
+
+func (identityNode) eval(input interface{}) ([]interface{}, error) {
+	return []interface{}{input}, nil
+}
+
+type recurseNode struct{}
+
+func (recurseNode) eval(input interface{}) ([]interface{}, error) {
+	results := []interface{}{input}
+	switch input.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		children, _ := iterate(input)
+		for _, c := range children {
+			r, _ := recurseNode{}.eval(c)
+			results = append(results, r...)
+		}
+	}
+	return results, nil
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(input interface{}) ([]interface{}, error) {
+	return []interface{}{n.value}, nil
+}
+
+type stringNode struct {
+	parts []stringPart
+}
+
+func (n *stringNode) eval(input interface{}) ([]interface{}, error) {
+	results := []interface{}{""}
+	for _, part := range n.parts {
+		if part.expr == nil {
+			for i := range results {
+				results[i] = results[i].(string) + part.text
+			}
+			continue
+		}
+		values, err := part.expr.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		var next []interface{}
+		for _, r := range results {
+			for _, v := range values {
+				next = append(next, r.(string)+exprString(v))
+			}
+		}
+		results = next
+	}
+	return results, nil
+}
+
+type indexNode struct {
+	target exprNode
+	index  exprNode
+}
+
+func (n *indexNode) eval(input interface{}) ([]interface{}, error) {
+	targets, err := n.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	// The index is evaluated against the original input, for example: .items[.selected]
+	indexes, err := n.index.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, t := range targets {
+		for _, i := range indexes {
+			v, err := index(t, i)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, v)
+		}
+	}
+	return results, nil
+}
+
+func index(m, i interface{}) (interface{}, error) {
+	switch t := m.(type) {
+	case nil:
+		switch i.(type) {
+		case string, int, float64, nil:
+			return nil, nil
+		}
+	case map[interface{}]interface{}:
+		if k, ok := i.(string); ok {
+			_, v, _ := mapKey(t, k)
+			return v, nil
+		}
+	case []interface{}:
+		if f, ok := toFloat(i); ok {
+			idx := int(math.Floor(f))
+			if idx < 0 {
+				idx += len(t)
+			}
+			if idx < 0 || idx >= len(t) {
+				return nil, nil
+			}
+			return t[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot index %s with %s", ErrExprEval, kindOf(m), exprDescribe(i))
+}
+
+type sliceNode struct {
+	target   exprNode
+	from, to exprNode
+}
+
+func (n *sliceNode) eval(input interface{}) ([]interface{}, error) {
+	targets, err := n.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(e exprNode) ([]interface{}, error) {
+		if e == nil {
+			return []interface{}{nil}, nil
+		}
+		return e.eval(input)
+	}
+	froms, err := bound(n.from)
+	if err != nil {
+		return nil, err
+	}
+	tos, err := bound(n.to)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, t := range targets {
+		for _, from := range froms {
+			for _, to := range tos {
+				v, err := slice(t, from, to)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, v)
+			}
+		}
+	}
+	return results, nil
+}
+
+func slice(m, from, to interface{}) (interface{}, error) {
+	var length int
+	switch t := m.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		length = len(t)
+	case string:
+		length = utf8.RuneCountInString(t)
+	default:
+		return nil, fmt.Errorf("%w: cannot slice %s", ErrExprEval, kindOf(m))
+	}
+	bound := func(b interface{}, def int) (int, error) {
+		if b == nil {
+			return def, nil
+		}
+		f, ok := toFloat(b)
+		if !ok {
+			return 0, fmt.Errorf("%w: slice indexes must be numbers, found %s", ErrExprEval, kindOf(b))
+		}
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i > length {
+			i = length
+		}
+		return i, nil
+	}
+	start, err := bound(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(to, length)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		end = start
+	}
+	if s, ok := m.(string); ok {
+		return string([]rune(s)[start:end]), nil
+	}
+	return append([]interface{}{}, m.([]interface{})[start:end]...), nil
+}
+
+type iterateNode struct {
+	target exprNode
+}
+
+func (n *iterateNode) eval(input interface{}) ([]interface{}, error) {
+	targets, err := n.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, t := range targets {
+		values, err := iterate(t)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, values...)
+	}
+	return results, nil
+}
+
+// iterate returns the list items or the map values in key order.
+func iterate(m interface{}) ([]interface{}, error) {
+	switch t := m.(type) {
+	case []interface{}:
+		return t, nil
+	case map[interface{}]interface{}:
+		keys := sortedKeys(t)
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = t[k]
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("%w: cannot iterate over %s", ErrExprEval, kindOf(m))
+}
+
+// sortedKeys returns the map keys sorted by their string representation.
+// Keys with the same representation, like 1 and "1", are sorted by type.
+func sortedKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := KeyString(keys[i]), KeyString(keys[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return fmt.Sprintf("%T", keys[i]) < fmt.Sprintf("%T", keys[j])
+	})
+	return keys
+}
+
+type tryNode struct {
+	body exprNode
+}
+
+func (n *tryNode) eval(input interface{}) ([]interface{}, error) {
+	results, err := n.body.eval(input)
+	if err != nil {
+		return []interface{}{}, nil
+	}
+	return results, nil
+}
+
+type pipeNode struct {
+	left, right exprNode
+}
+
+func (n *pipeNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, l := range lefts {
+		r, err := n.right.eval(l)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r...)
+	}
+	return results, nil
+}
+
+type commaNode struct {
+	left, right exprNode
+}
+
+func (n *commaNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return append(lefts, rights...), nil
+}
+
+type altNode struct {
+	left, right exprNode
+}
+
+func (n *altNode) eval(input interface{}) ([]interface{}, error) {
+	// Errors on the left side are ignored
+	lefts, _ := n.left.eval(input)
+	results := []interface{}{}
+	for _, l := range lefts {
+		if truthy(l) {
+			results = append(results, l)
+		}
+	}
+	if len(results) > 0 {
+		return results, nil
+	}
+	return n.right.eval(input)
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, l := range lefts {
+		// Short-circuit boolean operators
+		if (n.op == "and" && !truthy(l)) || (n.op == "or" && truthy(l)) {
+			results = append(results, n.op == "or")
+			continue
+		}
+		rights, err := n.right.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rights {
+			v, err := binaryOp(n.op, l, r)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, v)
+		}
+	}
+	return results, nil
+}
+
+func binaryOp(op string, l, r interface{}) (interface{}, error) {
+	switch op {
+	case "and", "or":
+		return truthy(r), nil
+	case "==":
+		return compareValues(l, r) == 0, nil
+	case "!=":
+		return compareValues(l, r) != 0, nil
+	case "<":
+		return compareValues(l, r) < 0, nil
+	case "<=":
+		return compareValues(l, r) <= 0, nil
+	case ">":
+		return compareValues(l, r) > 0, nil
+	case ">=":
+		return compareValues(l, r) >= 0, nil
+	case "+":
+		return add(l, r)
+	}
+	lf, lok := toFloat(l)
+	rf, rok := toFloat(r)
+	if op == "-" {
+		if ll, ok := l.([]interface{}); ok {
+			if rl, ok := r.([]interface{}); ok {
+				result := []interface{}{}
+				for _, v := range ll {
+					if !listContains(rl, v) {
+						result = append(result, v)
+					}
+				}
+				return result, nil
+			}
+		}
+	}
+	if !lok || !rok {
+		return nil, fmt.Errorf("%w: %s and %s cannot be used with '%s'", ErrExprEval, kindOf(l), kindOf(r), op)
+	}
+	li, lint := l.(int)
+	ri, rint := r.(int)
+	switch op {
+	case "-":
+		if lint && rint {
+			return li - ri, nil
+		}
+		return lf - rf, nil
+	case "*":
+		if lint && rint {
+			return li * ri, nil
+		}
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("%w: division by zero", ErrExprEval)
+		}
+		if lint && rint && li%ri == 0 {
+			return li / ri, nil
+		}
+		return lf / rf, nil
+	case "%":
+		if int(rf) == 0 {
+			return nil, fmt.Errorf("%w: division by zero", ErrExprEval)
+		}
+		return int(lf) % int(rf), nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator '%s'", ErrExprEval, op)
+}
+
+func add(l, r interface{}) (interface{}, error) {
+	if l == nil {
+		return r, nil
+	}
+	if r == nil {
+		return l, nil
+	}
+	if li, ok := l.(int); ok {
+		if ri, ok := r.(int); ok {
+			return li + ri, nil
+		}
+	}
+	if lf, ok := toFloat(l); ok {
+		if rf, ok := toFloat(r); ok {
+			return lf + rf, nil
+		}
+	}
+	switch lt := l.(type) {
+	case string:
+		if rt, ok := r.(string); ok {
+			return lt + rt, nil
+		}
+	case []interface{}:
+		if rt, ok := r.([]interface{}); ok {
+			return append(append([]interface{}{}, lt...), rt...), nil
+		}
+	case map[interface{}]interface{}:
+		if rt, ok := r.(map[interface{}]interface{}); ok {
+			result := make(map[interface{}]interface{}, len(lt)+len(rt))
+			for k, v := range lt {
+				result[k] = v
+			}
+			for k, v := range rt {
+				result[k] = v
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s and %s cannot be added", ErrExprEval, kindOf(l), kindOf(r))
+}
+
+type arrayNode struct {
+	body exprNode
+}
+
+func (n *arrayNode) eval(input interface{}) ([]interface{}, error) {
+	if n.body == nil {
+		return []interface{}{[]interface{}{}}, nil
+	}
+	values, err := n.body.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{append([]interface{}{}, values...)}, nil
+}
+
+type objectNode struct {
+	keys, values []exprNode
+}
+
+func (n *objectNode) eval(input interface{}) ([]interface{}, error) {
+	// Each combination of key and value outputs produces a map
+	results := []map[interface{}]interface{}{{}}
+	for i := range n.keys {
+		keys, err := n.keys[i].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		values, err := n.values[i].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		var next []map[interface{}]interface{}
+		for _, m := range results {
+			for _, k := range keys {
+				ks, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("%w: map keys must be strings, found %s", ErrExprEval, kindOf(k))
+				}
+				for _, v := range values {
+					c := make(map[interface{}]interface{}, len(m)+1)
+					for mk, mv := range m {
+						c[mk] = mv
+					}
+					c[ks] = v
+					next = append(next, c)
+				}
+			}
+		}
+		results = next
+	}
+	out := make([]interface{}, len(results))
+	for i, m := range results {
+		out[i] = m
+	}
+	return out, nil
+}
+
+type ifNode struct {
+	cond, then, otherwise exprNode
+}
+
+func (n *ifNode) eval(input interface{}) ([]interface{}, error) {
+	conds, err := n.cond.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	for _, c := range conds {
+		var r []interface{}
+		switch {
+		case truthy(c):
+			r, err = n.then.eval(input)
+		case n.otherwise != nil:
+			r, err = n.otherwise.eval(input)
+		default:
+			r = []interface{}{input}
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r...)
+	}
+	return results, nil
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+	// Compiled regex of test() when its argument is a literal
+	re *regexp.Regexp
+}
+
+func (n *callNode) eval(input interface{}) ([]interface{}, error) {
+	switch n.name {
+	case "empty":
+		return []interface{}{}, nil
+	case "select":
+		conds, err := n.args[0].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		results := []interface{}{}
+		for _, c := range conds {
+			if truthy(c) {
+				results = append(results, input)
+			}
+		}
+		return results, nil
+	case "map":
+		items, err := iterate(input)
+		if err != nil {
+			return nil, err
+		}
+		result := []interface{}{}
+		for _, item := range items {
+			r, err := n.args[0].eval(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, r...)
+		}
+		return []interface{}{result}, nil
+	}
+	if len(n.args) == 0 {
+		v, err := n.call(input, nil)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+	args, err := n.args[0].eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, 0, len(args))
+	for _, a := range args {
+		v, err := n.call(input, a)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, v)
+	}
+	return results, nil
+}
+
+// call returns the result of the function for the given input and argument value.
+func (n *callNode) call(input, arg interface{}) (interface{}, error) {
+	switch n.name {
+	case "length":
+		switch t := input.(type) {
+		case nil:
+			return 0, nil
+		case string:
+			return utf8.RuneCountInString(t), nil
+		case []interface{}:
+			return len(t), nil
+		case map[interface{}]interface{}:
+			return len(t), nil
+		case int:
+			if t < 0 {
+				return -t, nil
+			}
+			return t, nil
+		case float64:
+			return math.Abs(t), nil
+		}
+	case "keys":
+		switch t := input.(type) {
+		case map[interface{}]interface{}:
+			keys := []interface{}{}
+			for _, k := range sortedKeys(t) {
+				keys = append(keys, KeyString(k))
+			}
+			return keys, nil
+		case []interface{}:
+			keys := make([]interface{}, len(t))
+			for i := range t {
+				keys[i] = i
+			}
+			return keys, nil
+		}
+	case "has":
+		switch t := input.(type) {
+		case map[interface{}]interface{}:
+			if k, ok := arg.(string); ok {
+				_, _, found := mapKey(t, k)
+				return found, nil
+			}
+		case []interface{}:
+			if f, ok := toFloat(arg); ok {
+				return f >= 0 && int(f) < len(t), nil
+			}
+		}
+		return nil, fmt.Errorf("%w: cannot check whether %s has %s", ErrExprEval, kindOf(input), exprDescribe(arg))
+	case "not":
+		return !truthy(input), nil
+	case "type":
+		return kindOf(input), nil
+	case "sort":
+		if l, ok := input.([]interface{}); ok {
+			sorted := append([]interface{}{}, l...)
+			sort.SliceStable(sorted, func(i, j int) bool { return compareValues(sorted[i], sorted[j]) < 0 })
+			return sorted, nil
+		}
+	case "add":
+		items, err := iterate(input)
+		if err != nil {
+			return nil, err
+		}
+		var sum interface{}
+		for _, item := range items {
+			sum, err = add(sum, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return sum, nil
+	case "first", "last":
+		if l, ok := input.([]interface{}); ok {
+			if len(l) == 0 {
+				return nil, nil
+			}
+			if n.name == "first" {
+				return l[0], nil
+			}
+			return l[len(l)-1], nil
+		}
+	case "reverse":
+		switch t := input.(type) {
+		case []interface{}:
+			r := make([]interface{}, len(t))
+			for i, v := range t {
+				r[len(t)-1-i] = v
+			}
+			return r, nil
+		case string:
+			runes := []rune(t)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes), nil
+		}
+	case "tostring":
+		return exprString(input), nil
+	case "tonumber":
+		switch t := input.(type) {
+		case int, float64:
+			return t, nil
+		case string:
+			if i, err := strconv.Atoi(strings.TrimSpace(t)); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err == nil {
+				return f, nil
+			}
+			return nil, fmt.Errorf("%w: cannot convert %s to a number", ErrExprEval, exprDescribe(t))
+		}
+	case "to_entries":
+		if m, ok := input.(map[interface{}]interface{}); ok {
+			entries := []interface{}{}
+			for _, k := range sortedKeys(m) {
+				entries = append(entries, map[interface{}]interface{}{"key": KeyString(k), "value": m[k]})
+			}
+			return entries, nil
+		}
+	case "from_entries":
+		if l, ok := input.([]interface{}); ok {
+			m := map[interface{}]interface{}{}
+			for _, e := range l {
+				entry, ok := e.(map[interface{}]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%w: from_entries requires maps with key and value, found %s", ErrExprEval, kindOf(e))
+				}
+				k, _ := index(entry, "key")
+				if k == nil {
+					k, _ = index(entry, "name")
+				}
+				if k == nil {
+					return nil, fmt.Errorf("%w: from_entries requires maps with key and value", ErrExprEval)
+				}
+				v, _ := index(entry, "value")
+				m[exprString(k)] = v
+			}
+			return m, nil
+		}
+	case "join":
+		sep, ok := arg.(string)
+		items, err := iterate(input)
+		if !ok || err != nil {
+			break
+		}
+		values := make([]string, len(items))
+		for i, item := range items {
+			switch item.(type) {
+			case map[interface{}]interface{}, []interface{}:
+				return nil, fmt.Errorf("%w: cannot join %s", ErrExprEval, kindOf(item))
+			case nil:
+			default:
+				values[i] = exprString(item)
+			}
+		}
+		return strings.Join(values, sep), nil
+	case "startswith", "endswith", "split":
+		s, ok := input.(string)
+		a, aok := arg.(string)
+		if !ok || !aok {
+			return nil, fmt.Errorf("%w: %s requires string input and argument, found %s and %s", ErrExprEval, n.name,
+				kindOf(input), kindOf(arg))
+		}
+		switch n.name {
+		case "startswith":
+			return strings.HasPrefix(s, a), nil
+		case "endswith":
+			return strings.HasSuffix(s, a), nil
+		}
+		parts := []interface{}{}
+		for _, part := range strings.Split(s, a) {
+			parts = append(parts, part)
+		}
+		return parts, nil
+	case "contains":
+		if kindOf(input) != kindOf(arg) && !(isNumber(input) && isNumber(arg)) {
+			return nil, fmt.Errorf("%w: %s and %s cannot have their containment checked", ErrExprEval, kindOf(input),
+				kindOf(arg))
+		}
+		return contains(input, arg), nil
+	case "test":
+		s, ok := input.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: test requires string input, found %s", ErrExprEval, kindOf(input))
+		}
+		re := n.re
+		if re == nil {
+			pattern, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: test requires a string regex, found %s", ErrExprEval, kindOf(arg))
+			}
+			var err error
+			re, err = regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid regex: %s", ErrExprEval, err)
+			}
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("%w: %s can't be used with %s", ErrExprEval, n.name, kindOf(input))
+}
+
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+func isNumber(v interface{}) bool {
+	_, ok := toFloat(v)
+	return ok
+}
+
+// typeOrder - Position of each kind in the ordering used by compareValues.
+func typeOrder(v interface{}) int {
+	switch t := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if t {
+			return 2
+		}
+		return 1
+	case int, int64, uint64, float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	case map[interface{}]interface{}:
+		return 6
+	}
+	return 7
+}
+
+// compareValues returns -1, 0 or 1 when a is lower, equal or greater than b.
+// Values of different kinds are ordered as: null < false < true < numbers < strings < lists < maps.
+// Lists are compared item by item and maps by their sorted keys and then their values.
+func compareValues(a, b interface{}) int {
+	ao, bo := typeOrder(a), typeOrder(b)
+	if ao != bo {
+		return compareInts(ao, bo)
+	}
+	switch at := a.(type) {
+	case int, int64, uint64, float64:
+		af, _ := toFloat(a)
+		bf, _ := toFloat(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(at, b.(string))
+	case []interface{}:
+		bt := b.([]interface{})
+		for i := 0; i < len(at) && i < len(bt); i++ {
+			if c := compareValues(at[i], bt[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(at), len(bt))
+	case map[interface{}]interface{}:
+		bt := b.(map[interface{}]interface{})
+		ak, bk := sortedKeys(at), sortedKeys(bt)
+		aks, bks := make([]interface{}, len(ak)), make([]interface{}, len(bk))
+		for i, k := range ak {
+			aks[i] = KeyString(k)
+		}
+		for i, k := range bk {
+			bks[i] = KeyString(k)
+		}
+		if c := compareValues(aks, bks); c != 0 {
+			return c
+		}
+		for i := range ak {
+			if c := compareValues(at[ak[i]], bt[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func listContains(l []interface{}, v interface{}) bool {
+	for _, item := range l {
+		if compareValues(item, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// contains - Strings contain substrings, lists contain lists whose items are all contained in one of their items and
+// maps contain maps whose values are contained in the value with the same key.
+func contains(a, b interface{}) bool {
+	switch at := a.(type) {
+	case string:
+		return strings.Contains(at, b.(string))
+	case []interface{}:
+		for _, bv := range b.([]interface{}) {
+			found := false
+			for _, av := range at {
+				if kindOf(av) == kindOf(bv) && contains(av, bv) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case map[interface{}]interface{}:
+		for k, bv := range b.(map[interface{}]interface{}) {
+			_, av, ok := mapKey(at, KeyString(k))
+			if !ok || kindOf(av) != kindOf(bv) || !contains(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return compareValues(a, b) == 0
+}
+
+// exprString returns strings as is and other values as compact JSON.
+func exprString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return diffValue(v)
+}
+
+// exprDescribe returns the kind and JSON representation of v for error messages.
+func exprDescribe(v interface{}) string {
+	return fmt.Sprintf("%s (%s)", kindOf(v), diffValue(v))
+}
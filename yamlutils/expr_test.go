@@ -0,0 +1,212 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"testing"
+)
+
+var exprDoc = `
+name: app
+version: 1.5
+selected: 1
+db:
+  host: localhost
+  port: 5432
+  user: null
+tags: [web, api, edge]
+servers:
+  - name: api
+    port: 8080
+    tls: true
+  - name: web
+    port: 80
+  - name: admin
+    port: 9000
+    tls: false
+`
+
+func TestEvalExpr(t *testing.T) {
+	y, _ := NewFromString(exprDoc)
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		// Paths
+		{".", `{"db":{"host":"localhost","port":5432,"user":null},"name":"app","selected":1,"servers":[{"name":"api","port":8080,"tls":true},{"name":"web","port":80},{"name":"admin","port":9000,"tls":false}],"tags":["web","api","edge"],"version":1.5}`},
+		{".name", `"app"`},
+		{".db.host", `"localhost"`},
+		{`."db"."port"`, `5432`},
+		{`.db["port"]`, `5432`},
+		{".missing", `null`},
+		{".missing.deeper", `null`},
+		{".tags[0]", `"web"`},
+		{".tags[-1]", `"edge"`},
+		{".tags[10]", `null`},
+		{".tags[.selected]", `"api"`},
+		{".tags[1:]", `["api","edge"]`},
+		{".tags[:-1]", `["web","api"]`},
+		{".name[1:3]", `"pp"`},
+		{".tags[]", `"web" "api" "edge"`},
+		{".db[]", `"localhost" 5432 null`},
+		{".servers[].name", `"api" "web" "admin"`},
+		{".servers.[0].name", `"api"`},
+		{"[.. | .port? // empty]", `[5432,8080,80,9000]`},
+		{".name[0]?", ``},
+		{"(.name.x)?, 1", `1`},
+		// Pipes and commas
+		{".db | .host", `"localhost"`},
+		{".name, .db.port", `"app" 5432`},
+		{".servers[] | .name | length", `3 3 5`},
+		// Literals and construction
+		{`1, -2, 2.5, "s", true, false, null`, `1 -2 2.5 "s" true false null`},
+		{"[]", `[]`},
+		{"[.tags[0], .db.port]", `["web",5432]`},
+		{"{}", `{}`},
+		{`{name, "host": .db.host, (.tags[0]): 1, port: .db.port + 1}`, `{"host":"localhost","name":"app","port":5433,"web":1}`},
+		{"{a: (1, 2)}", `{"a":1} {"a":2}`},
+		{`"\(.name) v\(.version) on \(.db.host):\(.db.port) \(.tags[:1]) \("q\"")"`, `"app v1.5 on localhost:5432 [\"web\"] q\""`},
+		{`"a\tb\né"`, `"a\tb\né"`},
+		// Comparisons and boolean operators
+		{".db.port == 5432, .db.port != 5432, .version < 2, .version <= 1.5, .name > \"b\", 1 >= 1.0", `true false true true false true`},
+		{"null < false, false < true, true < 0, 0 < \"\", \"\" < [], [] < {}", `true true true true true true`},
+		{"[1, 2] < [1, 3], {a: 1} == {a: 1.0}", `true true`},
+		{"true and null, true or null, false or 1, .missing and 1", `false true true false`},
+		{".db.user // .db.host", `"localhost"`},
+		{"(.db.user, false) // 3", `3`},
+		{"not, (null | not)", `false true`},
+		// Arithmetic
+		{"1 + 2 * 3 - 4 / 2, 7 % 3, 5 / 2, 1.5 + 1", `5 1 2.5 2.5`},
+		{"-(1 + 2), -.db.port", `-3 -5432`},
+		{`"a" + "b", [1] + [2], {a: 1} + {b: 2}, null + 1`, `"ab" [1,2] {"a":1,"b":2} 1`},
+		{"[1, 2, 3, 1] - [1]", `[2,3]`},
+		// Conditionals
+		{`.servers[] | if .port < 1024 then "low" elif .tls then "tls" else "high" end`, `"tls" "low" "high"`},
+		{".db.user | if . then 1 end", `null`},
+		// Functions
+		{".servers[] | select(.tls) | .name", `"api"`},
+		{".servers | map(.port)", `[8080,80,9000]`},
+		{".servers | map(select(.port > 1024) | .name)", `["api","admin"]`},
+		{".db | map(. // 0)", `["localhost",5432,0]`},
+		{".db | keys, length", `["host","port","user"] 3`},
+		{".tags | keys", `[0,1,2]`},
+		{"null, -3, \"é\" | length", `0 3 1`},
+		{`.db | has("user"), has("password")`, `true false`},
+		{".tags | has(2), has(3)", `true false`},
+		{".servers[0] | .name, .port, .tls, .missing, . | type", `"string" "int" "bool" "null" "map"`},
+		{".version, .tags | type", `"float" "list"`},
+		{"[3, 1, null, \"a\", 2] | sort", `[null,1,2,3,"a"]`},
+		{".servers | map(.port) | add", `17160`},
+		{".tags | add, first, last, reverse, join(\"-\")", `"webapiedge" "web" "edge" ["edge","api","web"] "web-api-edge"`},
+		{"[] | add, first", `null null`},
+		{`.name | startswith("ap"), endswith("x"), contains("pp"), test("^a.p$"), split("p")`, `true false true true ["a","",""]`},
+		{`.tags | contains(["ap"])`, `true`},
+		{`.servers[0] | contains({name: "api"})`, `true`},
+		{".db.port | tostring, (tostring | tonumber)", `"5432" 5432`},
+		{`"1.5" | tonumber`, `1.5`},
+		{".db | to_entries | map(.key)", `["host","port","user"]`},
+		{".db | (to_entries | from_entries) == .", `true`},
+		{"[.servers[] | {(.name): .port}] | add", `{"admin":9000,"api":8080,"web":80}`},
+		{"empty, 1", `1`},
+		{"[.servers[] | .name] | length # comment", `3`},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			results, err := y.Eval(test.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output := ""
+			for i, r := range results {
+				if i > 0 {
+					output += " "
+				}
+				output += diffValue(r)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestEvalExprErrors(t *testing.T) {
+	y, _ := NewFromString(exprDoc)
+	tests := []struct {
+		expr string
+		err  error
+	}{
+		{"", ErrExprSyntax},
+		{".a |", ErrExprSyntax},
+		{".a)", ErrExprSyntax},
+		{"[1, 2", ErrExprSyntax},
+		{`"unterminated`, ErrExprSyntax},
+		{`"\(.a"`, ErrExprSyntax},
+		{`"\x"`, ErrExprSyntax},
+		{"{(.a)}", ErrExprSyntax},
+		{"if . then 1", ErrExprSyntax},
+		{"unknown", ErrExprSyntax},
+		{"select", ErrExprSyntax},
+		{`test("[")`, ErrExprSyntax},
+		{"1 == 2 == 3", ErrExprSyntax},
+		{"@", ErrExprSyntax},
+		{".name.first", ErrExprEval},
+		{".tags.name", ErrExprEval},
+		{".db[0]", ErrExprEval},
+		{".name[]", ErrExprEval},
+		{".name - 1", ErrExprEval},
+		{".db + .tags", ErrExprEval},
+		{"1 / 0", ErrExprEval},
+		{"{(1): 2}", ErrExprEval},
+		{".name | has(\"a\")", ErrExprEval},
+		{".tags | startswith(\"w\")", ErrExprEval},
+		{".name | contains(1)", ErrExprEval},
+		{".tags | join(\",\") | tonumber", ErrExprEval},
+		{".tags | length | keys", ErrExprEval},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := y.Eval(test.expr)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %v\n", err)
+			}
+		})
+	}
+}
+
+func TestEvalString(t *testing.T) {
+	y, _ := NewFromString(exprDoc)
+	tests := []struct {
+		expr     string
+		format   OutputFormat
+		expected string
+	}{
+		{".name", FormatYAML, "app\n"},
+		{".tags[]", FormatYAML, "web\napi\nedge\n"},
+		{".db.user", FormatYAML, "null\n"},
+		{".db", FormatYAML, "host: localhost\nport: 5432\nuser: null\n"},
+		{".servers[0], .tags", FormatYAML, "---\nname: api\nport: 8080\ntls: true\n---\n- web\n- api\n- edge\n"},
+		{"empty", FormatYAML, ""},
+		{".name, .db", FormatJSON, "\"app\"\n{\"host\":\"localhost\",\"port\":5432,\"user\":null}\n"},
+		{".tags[:1]", FormatJSONPretty, "[\n  \"web\"\n]\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			y.Format = test.format
+			output, err := y.EvalString(test.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
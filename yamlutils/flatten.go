@@ -0,0 +1,314 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultFlattenSeparator - Separator between the path and the value used when FlattenOptions.Separator is empty.
+const DefaultFlattenSeparator = "="
+
+// FlattenOptions - Options for Flatten and Unflatten.
+type FlattenOptions struct {
+	// Separator - Text between the path and the value, DefaultFlattenSeparator when empty.
+	// Occurrences of the separator in the path are escaped with a backslash.
+	Separator string
+
+	// Quote - Write values as JSON so strings are quoted and escaped, multi-line strings stay in a single line and
+	// Unflatten can tell strings like "8080" or "null" apart from numbers and nulls.
+	Quote bool
+}
+
+func (opts FlattenOptions) separator() string {
+	if opts.Separator == "" {
+		return DefaultFlattenSeparator
+	}
+	return opts.Separator
+}
+
+// Flatten returns one 'path=value' line for every scalar, empty map and empty list of the tree, for example:
+//
+//	db/host=localhost
+//	servers/0/name=api
+//	tags=[]
+//
+// Paths use the JoinPath syntax so they can be used as keys, map keys are sorted and list items are in index order.
+// Without quoting, null is written as 'null' and strings are written as is, multi-line strings return
+// ErrUnsupportedValue as they would break the one line per value format.
+func Flatten(tree interface{}, opts FlattenOptions) ([]string, error) {
+	return flatten(tree, []string{}, opts)
+}
+
+// Flatten returns the flattened lines of every element matched by the path, see Flatten.
+// The lines use the full path from the root of the document.
+func (y *YML) Flatten(keys []string, opts FlattenOptions) (string, error) {
+	matches, err := FindAll(y.Tree, keys)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, m := range matches {
+		lines, err := flatten(m.Value, m.Path, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, line := range lines {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
+
+func flatten(tree interface{}, prefix []string, opts FlattenOptions) ([]string, error) {
+	sep := opts.separator()
+	lines := []string{}
+	err := walkLeaves(tree, []interface{}{}, func(path []interface{}, value interface{}) error {
+		elements := append([]string{}, prefix...)
+		for _, e := range path {
+			elements = append(elements, pathKey(e))
+		}
+		p := strings.ReplaceAll(JoinPath(elements), sep, `\`+sep)
+		v, err := flatValue(value, opts.Quote)
+		if err != nil {
+			return fmt.Errorf("yaml path '%s': %w", JoinPath(elements), err)
+		}
+		lines = append(lines, p+sep+v)
+		return nil
+	})
+	return lines, err
+}
+
+func flatValue(value interface{}, quote bool) (string, error) {
+	if quote {
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(JSONCompatible(value))
+		return strings.TrimSuffix(buf.String(), "\n"), err
+	}
+	switch t := value.(type) {
+	case map[interface{}]interface{}:
+		return "{}", nil
+	case []interface{}:
+		return "[]", nil
+	case string:
+		if strings.ContainsAny(t, "\r\n") {
+			return "", fmt.Errorf("%w: multi-line strings require quoting", ErrUnsupportedValue)
+		}
+		return t, nil
+	}
+	return KeyString(value), nil
+}
+
+// Unflatten returns the tree described by lines in the Flatten format, the inverse of Flatten.
+// Blank lines are ignored.
+// Maps whose keys are exactly the indexes 0 to n-1 become lists.
+// Without quoting, values that are YAML numbers, 'true', 'false' or nulls keep their type, '{}' and '[]' are empty
+// containers and everything else is a string.
+// With quoting, values are JSON.
+func Unflatten(lines []string, opts FlattenOptions) (interface{}, error) {
+	sep := opts.separator()
+	var tree interface{}
+	for i, line := range lines {
+		n := i + 1
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		end := separatorIndex(line, sep)
+		if end == -1 {
+			return nil, fmt.Errorf("line %d: %w: missing separator '%s'", n, ErrInvalidInput, sep)
+		}
+		p, raw := line[:end], line[end+len(sep):]
+		var value interface{}
+		if opts.Quote {
+			var err error
+			value, err = readJSON([]byte(raw))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w: %s", n, ErrInvalidInput, err)
+			}
+		} else {
+			value = parseFlatValue(raw)
+		}
+		path := []interface{}{}
+		for _, e := range SplitPath(p) {
+			path = append(path, e)
+		}
+		var ok bool
+		tree, ok = setFlatValue(tree, path, value)
+		if !ok {
+			return nil, fmt.Errorf("line %d: %w: path '%s' conflicts with a previous path", n, ErrInvalidInput, p)
+		}
+	}
+	return listify(tree), nil
+}
+
+// separatorIndex returns the index of the first separator not escaped with a backslash, or -1.
+func separatorIndex(line, sep string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseFlatValue(s string) interface{} {
+	switch s {
+	case "{}":
+		return map[interface{}]interface{}{}
+	case "[]":
+		return []interface{}{}
+	}
+	// Avoid YAML comments, quotes and multi-line constructs changing the value
+	if s == "" || strings.ContainsAny(s, "#\"'\n") || strings.TrimSpace(s) != s {
+		return s
+	}
+	var v interface{}
+	err := yaml.Unmarshal([]byte(s), &v)
+	if err != nil {
+		return s
+	}
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}, string:
+		return s
+	case bool:
+		// YAML 1.1 booleans like 'y' or 'on' stay strings
+		if s != "true" && s != "false" {
+			return s
+		}
+	}
+	return v
+}
+
+// listify converts the maps whose keys are exactly the indexes 0 to n-1 into lists.
+func listify(tree interface{}) interface{} {
+	m, ok := tree.(map[interface{}]interface{})
+	if !ok {
+		return tree
+	}
+	for k, v := range m {
+		m[k] = listify(v)
+	}
+	if len(m) == 0 {
+		return m
+	}
+	list := make([]interface{}, len(m))
+	for k, v := range m {
+		i, err := strconv.Atoi(KeyString(k))
+		if err != nil || i < 0 || i >= len(m) || strconv.Itoa(i) != KeyString(k) {
+			return m
+		}
+		list[i] = v
+	}
+	return list
+}
+
+// maxListIndex - Largest list index accepted when building a Tree from flat keys, it limits the memory used by
+// sparse indexes.
+const maxListIndex = 100000
+
+// setFlatValue sets value at the path of map keys (string) and list indexes (int) creating the missing maps and
+// lists, list gaps are filled with nil.
+// It returns the updated tree and false when the path conflicts with an existing element.
+func setFlatValue(tree interface{}, path []interface{}, value interface{}) (interface{}, bool) {
+	if len(path) == 0 {
+		switch t := tree.(type) {
+		case map[interface{}]interface{}:
+			return tree, len(t) == 0 && isEmptyContainer(value)
+		case []interface{}:
+			return tree, len(t) == 0 && isEmptyContainer(value)
+		}
+		return value, true
+	}
+	switch e := path[0].(type) {
+	case int:
+		if tree == nil {
+			tree = []interface{}{}
+		}
+		l, ok := tree.([]interface{})
+		if !ok || e < 0 {
+			return tree, false
+		}
+		for len(l) <= e {
+			l = append(l, nil)
+		}
+		l[e], ok = setFlatValue(l[e], path[1:], value)
+		return l, ok
+	default:
+		if tree == nil {
+			tree = map[interface{}]interface{}{}
+		}
+		m, ok := tree.(map[interface{}]interface{})
+		if !ok {
+			return tree, false
+		}
+		k, v, _ := mapKey(m, KeyString(e))
+		m[k], ok = setFlatValue(v, path[1:], value)
+		return m, ok
+	}
+}
+
+func isEmptyContainer(v interface{}) bool {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// walkLeaves calls fn with the path and value of every scalar, empty map and empty list of the tree.
+// Map keys are visited in sorted order and list items in index order.
+// The path holds map keys (string) and list indexes (int).
+func walkLeaves(tree interface{}, path []interface{}, fn func(path []interface{}, value interface{}) error) error {
+	if isEmptyContainer(tree) {
+		return fn(path, tree)
+	}
+	switch t := tree.(type) {
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(t))
+		values := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			keys = append(keys, KeyString(k))
+			values[KeyString(k)] = v
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			err := walkLeaves(values[k], append(path[:len(path):len(path)], k), fn)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		for i, v := range t {
+			err := walkLeaves(v, append(path[:len(path):len(path)], i), fn)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fn(path, tree)
+}
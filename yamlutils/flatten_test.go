@@ -0,0 +1,208 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var flattenDoc = `
+db:
+  host: localhost
+  port: 5432
+  password: null
+servers:
+  - name: api
+    ports: [80, 443]
+  - name: "web"
+    tags: []
+labels:
+  app.kubernetes.io/name: api
+  a=b: equals
+notes: "line 1\nline 2"
+empty: ""
+quoted: "8080"
+`
+
+func TestFlatten(t *testing.T) {
+	y, _ := NewFromString(flattenDoc)
+	tests := []struct {
+		name     string
+		opts     FlattenOptions
+		expected []string
+	}{
+		{"default", FlattenOptions{}, []string{
+			"db/host=localhost",
+			"db/password=null",
+			"db/port=5432",
+			"empty=",
+			`labels/a\=b=equals`,
+			`labels/app.kubernetes.io\/name=api`,
+			"quoted=8080",
+			"servers/0/name=api",
+			"servers/0/ports/0=80",
+			"servers/0/ports/1=443",
+			"servers/1/name=web",
+			"servers/1/tags=[]",
+		}},
+		{"quote and separator", FlattenOptions{Separator: ": ", Quote: true}, []string{
+			`db/host: "localhost"`,
+			`db/password: null`,
+			`db/port: 5432`,
+			`empty: ""`,
+			`labels/a=b: "equals"`,
+			`labels/app.kubernetes.io\/name: "api"`,
+			`notes: "line 1\nline 2"`,
+			`quoted: "8080"`,
+			`servers/0/name: "api"`,
+			`servers/0/ports/0: 80`,
+			`servers/0/ports/1: 443`,
+			`servers/1/name: "web"`,
+			`servers/1/tags: []`,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := deepCopy(y.Tree)
+			if !test.opts.Quote {
+				// Multi-line strings require quoting, see TestFlattenMultiLine
+				delete(tree.(map[interface{}]interface{}), "notes")
+			}
+			output, err := Flatten(tree, test.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", strings.Join(test.expected, "\n"), strings.Join(output, "\n"))
+			}
+		})
+	}
+}
+
+func TestFlattenMultiLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		opts  FlattenOptions
+		err   error
+	}{
+		{"newline", map[interface{}]interface{}{"a": "x\ny"}, FlattenOptions{}, ErrUnsupportedValue},
+		{"carriage return", []interface{}{"x\r"}, FlattenOptions{}, ErrUnsupportedValue},
+		{"quoted", map[interface{}]interface{}{"a": "x\ny"}, FlattenOptions{Quote: true}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Flatten(test.input, test.opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %v\n", err)
+			}
+		})
+	}
+}
+
+func TestFlattenScalar(t *testing.T) {
+	output, err := Flatten("value", FlattenOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if !reflect.DeepEqual(output, []string{"=value"}) {
+		t.Errorf("Unexpected output: %#v\n", output)
+	}
+}
+
+func TestYMLFlatten(t *testing.T) {
+	y, _ := NewFromString(flattenDoc)
+	tests := []struct {
+		path     string
+		expected string
+		err      error
+	}{
+		{"db/host", "db/host=localhost\n", nil},
+		{"servers/*/name", "servers/0/name=api\nservers/1/name=web\n", nil},
+		{"servers/[name=api]/ports", "servers/0/ports/0=80\nservers/0/ports/1=443\n", nil},
+		{"missing", "", ErrMapKeyNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			output, err := y.Flatten(SplitPath(test.path), FlattenOptions{})
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		opts     FlattenOptions
+		expected interface{}
+		err      error
+	}{
+		{"empty", []string{}, FlattenOptions{}, nil, nil},
+		{"types", []string{"a=1", "b=2.5", "c=true", "d=null", "e=text", "f=", "g={}", "h=[]", "i=x # y", "j=[1]", "k=on"},
+			FlattenOptions{}, map[interface{}]interface{}{
+				"a": 1, "b": 2.5, "c": true, "d": nil, "e": "text", "f": "",
+				"g": map[interface{}]interface{}{}, "h": []interface{}{}, "i": "x # y", "j": "[1]", "k": "on",
+			}, nil},
+		{"lists", []string{"a/1/b=y", "a/0/b=x", "c/0=1"}, FlattenOptions{}, map[interface{}]interface{}{
+			"a": []interface{}{
+				map[interface{}]interface{}{"b": "x"},
+				map[interface{}]interface{}{"b": "y"},
+			},
+			"c": []interface{}{1},
+		}, nil},
+		{"sparse indexes", []string{"a/0=x", "a/2=y"}, FlattenOptions{}, map[interface{}]interface{}{
+			"a": map[interface{}]interface{}{"0": "x", "2": "y"},
+		}, nil},
+		{"escaped paths", []string{`a\=b=c=d`, `x\/y=z`, "blank=line", ""}, FlattenOptions{}, map[interface{}]interface{}{
+			"a=b": "c=d", "x/y": "z", "blank": "line",
+		}, nil},
+		{"quoted", []string{`a: "8080"`, `b: null`, `c: "x\ny"`, `d: [1, "2"]`}, FlattenOptions{Separator: ": ", Quote: true},
+			map[interface{}]interface{}{"a": "8080", "b": nil, "c": "x\ny", "d": []interface{}{1, "2"}}, nil},
+		{"root scalar", []string{"=value"}, FlattenOptions{}, "value", nil},
+		{"missing separator", []string{"a"}, FlattenOptions{}, nil, ErrInvalidInput},
+		{"conflict", []string{"a=1", "a/b=2"}, FlattenOptions{}, nil, ErrInvalidInput},
+		{"invalid json", []string{"a=x"}, FlattenOptions{Quote: true}, nil, ErrInvalidInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := Unflatten(test.lines, test.opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if err == nil && !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected: %#v, got: %#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestFlattenRoundTrip(t *testing.T) {
+	y, _ := NewFromString(flattenDoc)
+	opts := FlattenOptions{Separator: "\t", Quote: true}
+	lines, err := Flatten(y.Tree, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	tree, err := Unflatten(lines, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if !reflect.DeepEqual(tree, y.Tree) {
+		t.Errorf("Expected: %#v, got: %#v\n", y.Tree, tree)
+	}
+}
@@ -0,0 +1,106 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// OutputFormat - Format used to marshal the results of YML operations.
+type OutputFormat string
+
+const (
+	// FormatYAML - YAML output, the default.
+	FormatYAML OutputFormat = "yaml"
+	// FormatJSON - Compact JSON output in a single line.
+	FormatJSON OutputFormat = "json"
+	// FormatJSONPretty - Indented JSON output.
+	FormatJSONPretty OutputFormat = "json-pretty"
+)
+
+// ErrInvalidFormat - The given format is not supported.
+var ErrInvalidFormat = fmt.Errorf("invalid format")
+
+// ParseOutputFormat returns the OutputFormat for the given name.
+// Valid names are: yaml, yml, json, for compact JSON, json-pretty and pretty.
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "yaml", "yml":
+		return FormatYAML, nil
+	case "json":
+		return FormatJSON, nil
+	case "json-pretty", "pretty":
+		return FormatJSONPretty, nil
+	}
+	return FormatYAML, fmt.Errorf("%w: %s", ErrInvalidFormat, name)
+}
+
+// JSONCompatible returns a copy of the tree where all map[interface{}]interface{} elements are converted into
+// map[string]interface{} so the tree can be handled by encoding/json.
+// Non-string keys are converted with their %v representation.
+func JSONCompatible(m interface{}) interface{} {
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		r := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			r[KeyString(k)] = JSONCompatible(v)
+		}
+		return r
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			r[k] = JSONCompatible(v)
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(t))
+		for i, v := range t {
+			r[i] = JSONCompatible(v)
+		}
+		return r
+	default:
+		return m
+	}
+}
+
+func (y *YML) jsonOutput() bool {
+	return y.Format == FormatJSON || y.Format == FormatJSONPretty
+}
+
+// marshal returns the representation of m in the YML output format.
+func (y *YML) marshal(m interface{}) ([]byte, error) {
+	if y.jsonOutput() {
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if y.Format == FormatJSONPretty {
+			enc.SetIndent("", "  ")
+		}
+		err := enc.Encode(JSONCompatible(m))
+		return buf.Bytes(), err
+	}
+	return yaml.Marshal(m)
+}
+
+// scalarString returns the representation of a single element in the YML output format.
+// YAML output returns the plain value without quoting.
+func (y *YML) scalarString(o interface{}) string {
+	if y.jsonOutput() {
+		out, err := y.marshal(o)
+		if err == nil {
+			return strings.TrimSuffix(string(out), "\n")
+		}
+	}
+	return fmt.Sprintf("%v", o)
+}
@@ -0,0 +1,101 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected OutputFormat
+		err      error
+	}{
+		{"", FormatYAML, nil},
+		{"yml", FormatYAML, nil},
+		{"JSON", FormatJSON, nil},
+		{"json-compact", FormatYAML, ErrInvalidFormat},
+		{"json-pretty", FormatJSONPretty, nil},
+		{"xml", FormatYAML, ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := ParseOutputFormat(test.name)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestJSONCompatible(t *testing.T) {
+	input := map[interface{}]interface{}{
+		"hello": []interface{}{map[interface{}]interface{}{8080: true}},
+		nil:     "null key",
+	}
+	expected := map[string]interface{}{
+		"hello": []interface{}{map[string]interface{}{"8080": true}},
+		"null":  "null key",
+	}
+	output := JSONCompatible(input)
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v\n", expected, output)
+	}
+}
+
+func TestGetStringFormat(t *testing.T) {
+	input := `hello:
+  - one
+  - world: "<b>"
+    number: 123
+`
+	tests := []struct {
+		name     string
+		format   OutputFormat
+		path     []string
+		expected string
+	}{
+		{"yaml scalar", FormatYAML, []string{"hello", "1", "world"}, "<b>"},
+		{"json scalar", FormatJSON, []string{"hello", "1", "world"}, `"<b>"`},
+		{"json number", FormatJSON, []string{"hello", "1", "number"}, `123`},
+		{"json", FormatJSON, []string{}, `{"hello":["one",{"number":123,"world":"<b>"}]}` + "\n"},
+		{"json pretty", FormatJSONPretty, []string{"hello", "1"}, `{
+  "number": 123,
+  "world": "<b>"
+}
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			yml, err := NewFromString(input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			yml.Format = test.format
+			output, err := yml.GetString(false, test.path)
+			if err != nil {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+			t.Log(buf.String())
+		})
+	}
+}
@@ -0,0 +1,327 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrTypeMismatch - The element doesn't have the requested type.
+var ErrTypeMismatch = fmt.Errorf("type mismatch")
+
+// kindOf returns the name of the type of the tree element: map, list, string, int, float, bool or null.
+func kindOf(m interface{}) string {
+	switch m.(type) {
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case int, int64, uint64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+// lookup returns the element at the given path, missing is true when the path doesn't exist.
+func (y *YML) lookup(keys []string) (interface{}, bool, error) {
+	target, _, err := NavigateTree(false, y.Tree, keys)
+	if errors.Is(err, ErrMapKeyNotFound) || errors.Is(err, ErrInvalidIndex) || errors.Is(err, ErrNoMatch) {
+		return nil, true, fmt.Errorf("yaml path '%s': %w", JoinPath(keys), err)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("yaml path '%s': %w", JoinPath(keys), err)
+	}
+	return target, false, nil
+}
+
+func typeMismatch(keys []string, m interface{}, expected string) error {
+	return fmt.Errorf("%w: yaml path '%s' is %s, expected %s", ErrTypeMismatch, JoinPath(keys), kindOf(m), expected)
+}
+
+// GetInt returns the integer at the given path.
+// Floats are not converted, a float element returns ErrTypeMismatch.
+func (y *YML) GetInt(keys []string) (int, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return 0, err
+	}
+	return toInt(keys, target)
+}
+
+func toInt(keys []string, m interface{}) (int, error) {
+	switch t := m.(type) {
+	case int:
+		return t, nil
+	case int64:
+		if int64(int(t)) == t {
+			return int(t), nil
+		}
+		return 0, fmt.Errorf("%w: yaml path '%s' value %d overflows int", ErrTypeMismatch, JoinPath(keys), t)
+	case uint64:
+		if t <= uint64(^uint(0)>>1) {
+			return int(t), nil
+		}
+		return 0, fmt.Errorf("%w: yaml path '%s' value %d overflows int", ErrTypeMismatch, JoinPath(keys), t)
+	}
+	return 0, typeMismatch(keys, m, "int")
+}
+
+// GetIntDefault returns the integer at the given path or def when the path doesn't exist or is null.
+func (y *YML) GetIntDefault(keys []string, def int) (int, error) {
+	target, missing, err := y.lookup(keys)
+	if missing || (err == nil && target == nil) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return toInt(keys, target)
+}
+
+// GetBool returns the boolean at the given path.
+func (y *YML) GetBool(keys []string) (bool, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return false, err
+	}
+	return toBool(keys, target)
+}
+
+func toBool(keys []string, m interface{}) (bool, error) {
+	if b, ok := m.(bool); ok {
+		return b, nil
+	}
+	return false, typeMismatch(keys, m, "bool")
+}
+
+// GetBoolDefault returns the boolean at the given path or def when the path doesn't exist or is null.
+func (y *YML) GetBoolDefault(keys []string, def bool) (bool, error) {
+	target, missing, err := y.lookup(keys)
+	if missing || (err == nil && target == nil) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return toBool(keys, target)
+}
+
+// GetFloat returns the number at the given path, integers are converted to float64.
+func (y *YML) GetFloat(keys []string) (float64, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return 0, err
+	}
+	return toFloat64(keys, target)
+}
+
+func toFloat64(keys []string, m interface{}) (float64, error) {
+	if f, ok := toFloat(m); ok {
+		return f, nil
+	}
+	return 0, typeMismatch(keys, m, "float")
+}
+
+// GetFloatDefault returns the number at the given path or def when the path doesn't exist or is null.
+func (y *YML) GetFloatDefault(keys []string, def float64) (float64, error) {
+	target, missing, err := y.lookup(keys)
+	if missing || (err == nil && target == nil) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return toFloat64(keys, target)
+}
+
+// GetDuration returns the duration at the given path.
+// The element must be a string accepted by time.ParseDuration, for example: 1h30m or 500ms.
+func (y *YML) GetDuration(keys []string) (time.Duration, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return 0, err
+	}
+	return toDuration(keys, target)
+}
+
+func toDuration(keys []string, m interface{}) (time.Duration, error) {
+	s, ok := m.(string)
+	if !ok {
+		return 0, typeMismatch(keys, m, "duration")
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: yaml path '%s' is not a duration: %s", ErrTypeMismatch, JoinPath(keys), err)
+	}
+	return d, nil
+}
+
+// GetDurationDefault returns the duration at the given path or def when the path doesn't exist or is null.
+func (y *YML) GetDurationDefault(keys []string, def time.Duration) (time.Duration, error) {
+	target, missing, err := y.lookup(keys)
+	if missing || (err == nil && target == nil) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return toDuration(keys, target)
+}
+
+// GetStringSlice returns the list of strings at the given path.
+// Integer, float and boolean list items are converted to their string representation, maps, lists and nulls return
+// ErrTypeMismatch.
+func (y *YML) GetStringSlice(keys []string) ([]string, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return nil, err
+	}
+	return toStringSlice(keys, target)
+}
+
+func toStringSlice(keys []string, m interface{}) ([]string, error) {
+	list, ok := m.([]interface{})
+	if !ok {
+		return nil, typeMismatch(keys, m, "list")
+	}
+	r := make([]string, len(list))
+	for i, e := range list {
+		switch e.(type) {
+		case string, int, int64, uint64, float64, bool:
+			r[i] = fmt.Sprintf("%v", e)
+		default:
+			return nil, typeMismatch(appendPath(keys, fmt.Sprint(i)), e, "string")
+		}
+	}
+	return r, nil
+}
+
+// GetStringSliceDefault returns the list of strings at the given path or def when the path doesn't exist or is null.
+func (y *YML) GetStringSliceDefault(keys []string, def []string) ([]string, error) {
+	target, missing, err := y.lookup(keys)
+	if missing || (err == nil && target == nil) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	return toStringSlice(keys, target)
+}
+
+// Kind returns the type name of the element at the given path: map, list, string, int, float, bool or null.
+func (y *YML) Kind(keys []string) (string, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return "", err
+	}
+	return kindOf(target), nil
+}
+
+// Keys returns the sorted keys of the map at the given path, or the indexes of the list at the given path.
+// Other elements return ErrTypeMismatch.
+func (y *YML) Keys(keys []string) ([]string, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return nil, err
+	}
+	switch t := target.(type) {
+	case map[interface{}]interface{}:
+		r := make([]string, 0, len(t))
+		for k := range t {
+			r = append(r, KeyString(k))
+		}
+		sort.Strings(r)
+		return r, nil
+	case map[string]interface{}:
+		r := make([]string, 0, len(t))
+		for k := range t {
+			r = append(r, k)
+		}
+		sort.Strings(r)
+		return r, nil
+	case []interface{}:
+		r := make([]string, len(t))
+		for i := range t {
+			r[i] = strconv.Itoa(i)
+		}
+		return r, nil
+	}
+	return nil, typeMismatch(keys, target, "map or list")
+}
+
+// Len returns the number of elements of the map or list at the given path, or the number of characters of the
+// string at the given path.
+// Other elements return ErrTypeMismatch.
+func (y *YML) Len(keys []string) (int, error) {
+	target, _, err := y.lookup(keys)
+	if err != nil {
+		return 0, err
+	}
+	switch t := target.(type) {
+	case map[interface{}]interface{}:
+		return len(t), nil
+	case map[string]interface{}:
+		return len(t), nil
+	case []interface{}:
+		return len(t), nil
+	case string:
+		return utf8.RuneCountInString(t), nil
+	}
+	return 0, typeMismatch(keys, target, "map, list or string")
+}
+
+// KindString returns the result of Kind in the YML output format.
+func (y *YML) KindString(keys []string) (string, error) {
+	kind, err := y.Kind(keys)
+	if err != nil {
+		return "", err
+	}
+	return y.scalarString(kind), nil
+}
+
+// KeysString returns the result of Keys in the YML output format.
+// YAML output returns one key per line, JSON output returns a list.
+func (y *YML) KeysString(keys []string) (string, error) {
+	r, err := y.Keys(keys)
+	if err != nil {
+		return "", err
+	}
+	if y.jsonOutput() {
+		out, err := y.marshal(r)
+		return string(out), err
+	}
+	var b strings.Builder
+	for _, k := range r {
+		b.WriteString(k + "\n")
+	}
+	return b.String(), nil
+}
+
+// LenString returns the result of Len in the YML output format.
+func (y *YML) LenString(keys []string) (string, error) {
+	n, err := y.Len(keys)
+	if err != nil {
+		return "", err
+	}
+	return y.scalarString(n), nil
+}
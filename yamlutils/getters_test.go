@@ -0,0 +1,338 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var gettersDoc = `
+port: 8080
+big: 18446744073709551615
+ratio: 0.5
+enabled: true
+quoted: "true"
+timeout: 1m30s
+name: api
+null_value: null
+hosts: [a, b]
+mixed: [a, 1, 2.5, false]
+nested: [a, [b]]
+items:
+  - count: 3
+`
+
+func TestGetInt(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected int
+		err      error
+	}{
+		{[]string{"port"}, 8080, nil},
+		{[]string{"items", "0", "count"}, 3, nil},
+		{[]string{"ratio"}, 0, ErrTypeMismatch},
+		{[]string{"name"}, 0, ErrTypeMismatch},
+		{[]string{"null_value"}, 0, ErrTypeMismatch},
+		{[]string{"big"}, 0, ErrTypeMismatch},
+		{[]string{"missing"}, 0, ErrMapKeyNotFound},
+		{[]string{"port", "x"}, 0, ErrExtraElementsInPath},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.GetInt(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %d, got: %d\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	output, err := y.GetBool([]string{"enabled"})
+	if err != nil || output != true {
+		t.Errorf("Unexpected output: %v, %v\n", output, err)
+	}
+	_, err = y.GetBool([]string{"quoted"})
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+	expected := "type mismatch: yaml path 'quoted' is string, expected bool"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected: %s, got: %v\n", expected, err)
+	}
+}
+
+func TestGetFloat(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected float64
+		err      error
+	}{
+		{[]string{"ratio"}, 0.5, nil},
+		{[]string{"port"}, 8080, nil},
+		{[]string{"enabled"}, 0, ErrTypeMismatch},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.GetFloat(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %v, got: %v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestGetDuration(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected time.Duration
+		err      error
+	}{
+		{[]string{"timeout"}, 90 * time.Second, nil},
+		{[]string{"name"}, 0, ErrTypeMismatch},
+		{[]string{"port"}, 0, ErrTypeMismatch},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.GetDuration(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %v, got: %v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestGetStringSlice(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected []string
+		err      error
+	}{
+		{[]string{"hosts"}, []string{"a", "b"}, nil},
+		{[]string{"mixed"}, []string{"a", "1", "2.5", "false"}, nil},
+		{[]string{"nested"}, nil, ErrTypeMismatch},
+		{[]string{"name"}, nil, ErrTypeMismatch},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.GetStringSlice(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected: %v, got: %v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestGetDefault(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	i, err := y.GetIntDefault([]string{"missing"}, 1)
+	if err != nil || i != 1 {
+		t.Errorf("Unexpected output: %v, %v\n", i, err)
+	}
+	i, err = y.GetIntDefault([]string{"items", "5", "count"}, 2)
+	if err != nil || i != 2 {
+		t.Errorf("Unexpected output: %v, %v\n", i, err)
+	}
+	i, err = y.GetIntDefault([]string{"port"}, 1)
+	if err != nil || i != 8080 {
+		t.Errorf("Unexpected output: %v, %v\n", i, err)
+	}
+	_, err = y.GetIntDefault([]string{"name"}, 1)
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+	_, err = y.GetIntDefault([]string{"port", "x"}, 1)
+	if !errors.Is(err, ErrExtraElementsInPath) {
+		t.Errorf("Unexpected error: %v\n", err)
+	}
+	b, err := y.GetBoolDefault([]string{"null_value"}, true)
+	if err != nil || b != true {
+		t.Errorf("Unexpected output: %v, %v\n", b, err)
+	}
+	f, err := y.GetFloatDefault([]string{"missing"}, 1.5)
+	if err != nil || f != 1.5 {
+		t.Errorf("Unexpected output: %v, %v\n", f, err)
+	}
+	d, err := y.GetDurationDefault([]string{"missing"}, time.Second)
+	if err != nil || d != time.Second {
+		t.Errorf("Unexpected output: %v, %v\n", d, err)
+	}
+	s, err := y.GetStringSliceDefault([]string{"missing"}, []string{"x"})
+	if err != nil || !reflect.DeepEqual(s, []string{"x"}) {
+		t.Errorf("Unexpected output: %v, %v\n", s, err)
+	}
+}
+
+func TestKind(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected string
+		err      error
+	}{
+		{[]string{}, "map", nil},
+		{[]string{"hosts"}, "list", nil},
+		{[]string{"name"}, "string", nil},
+		{[]string{"quoted"}, "string", nil},
+		{[]string{"port"}, "int", nil},
+		{[]string{"big"}, "int", nil},
+		{[]string{"ratio"}, "float", nil},
+		{[]string{"enabled"}, "bool", nil},
+		{[]string{"null_value"}, "null", nil},
+		{[]string{"items", "0"}, "map", nil},
+		{[]string{"missing"}, "", ErrMapKeyNotFound},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.Kind(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected []string
+		err      error
+	}{
+		{[]string{"items", "0"}, []string{"count"}, nil},
+		{[]string{"mixed"}, []string{"0", "1", "2", "3"}, nil},
+		{[]string{}, []string{"big", "enabled", "hosts", "items", "mixed", "name", "nested", "null_value", "port", "quoted",
+			"ratio", "timeout"}, nil},
+		{[]string{"name"}, nil, ErrTypeMismatch},
+		{[]string{"null_value"}, nil, ErrTypeMismatch},
+		{[]string{"missing"}, nil, ErrMapKeyNotFound},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.Keys(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected: %v, got: %v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestStringKeyMap(t *testing.T) {
+	y := &YML{Tree: map[string]interface{}{"b": 1, "a": []interface{}{1, 2}}}
+	tests := []struct {
+		path []string
+		keys []string
+		len  int
+	}{
+		{[]string{}, []string{"a", "b"}, 2},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			keys, err := y.Keys(test.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(keys, test.keys) {
+				t.Errorf("Expected: %v, got: %v\n", test.keys, keys)
+			}
+			length, err := y.Len(test.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if length != test.len {
+				t.Errorf("Expected: %d, got: %d\n", test.len, length)
+			}
+		})
+	}
+}
+
+func TestLen(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		path     []string
+		expected int
+		err      error
+	}{
+		{[]string{}, 12, nil},
+		{[]string{"mixed"}, 4, nil},
+		{[]string{"items", "0"}, 1, nil},
+		{[]string{"name"}, 3, nil},
+		{[]string{"port"}, 0, ErrTypeMismatch},
+		{[]string{"null_value"}, 0, ErrTypeMismatch},
+		{[]string{"hosts", "5"}, 0, ErrInvalidIndex},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test.path), func(t *testing.T) {
+			output, err := y.Len(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %d, got: %d\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestInspectString(t *testing.T) {
+	y, _ := NewFromString(gettersDoc)
+	tests := []struct {
+		name     string
+		format   OutputFormat
+		fn       func([]string) (string, error)
+		path     []string
+		expected string
+	}{
+		{"keys yaml", FormatYAML, y.KeysString, []string{"items", "0"}, "count\n"},
+		{"keys list yaml", FormatYAML, y.KeysString, []string{"hosts"}, "0\n1\n"},
+		{"keys json", FormatJSON, y.KeysString, []string{"hosts"}, "[\"0\",\"1\"]\n"},
+		{"length yaml", FormatYAML, y.LenString, []string{"hosts"}, "2"},
+		{"length json", FormatJSON, y.LenString, []string{"name"}, "3"},
+		{"type yaml", FormatYAML, y.KindString, []string{"ratio"}, "float"},
+		{"type json", FormatJSON, y.KindString, []string{"hosts"}, `"list"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y.Format = test.format
+			output, err := test.fn(test.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %q, got: %q\n", test.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// IncludeTag - Tag used to include the contents of another file.
+const IncludeTag = "!include"
+
+// ErrIncludeCycle - A file includes itself directly or through other files.
+var ErrIncludeCycle = fmt.Errorf("include cycle")
+
+// ErrInvalidInclude - The include tag is not used on a file path.
+var ErrInvalidInclude = fmt.Errorf("invalid include")
+
+// NewFromFileWithIncludes returns a pointer to a YML object from a file where the elements tagged with '!include'
+// are replaced by the contents of the given file, for example:
+//
+//	database: !include database.yml
+//	services: !include services/*.yml
+//
+// Paths are relative to the directory of the including file.
+// A glob pattern is replaced by the list of the contents of each matching file in lexical order, an empty list
+// when nothing matches.
+// Included files can include other files, an include cycle returns ErrIncludeCycle.
+// Writing the document back, for example with WriteFile, inlines the included files.
+func NewFromFileWithIncludes(filename string) (*YML, error) {
+	node, err := readIncludeFile(filename, []string{})
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return &YML{}, nil
+	}
+	data, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	err = yaml.Unmarshal(data, &tree)
+	if err != nil {
+		return nil, err
+	}
+	return &YML{Tree: tree, source: data}, nil
+}
+
+// readIncludeFile returns the root node of the file with its includes resolved.
+// stack holds the files being included to detect cycles.
+func readIncludeFile(filename string, stack []string) (*yaml3.Node, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range stack {
+		if f == filename {
+			return nil, fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(stack, filename), " -> "))
+		}
+	}
+	Logger.Printf("include: reading %s", filename)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml3.Node
+	err = yaml3.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	stack = append(stack, filename)
+	root := doc.Content[0]
+	err = resolveIncludes(root, filepath.Dir(filename), stack)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// resolveIncludes replaces in place the include nodes found under node.
+func resolveIncludes(node *yaml3.Node, dir string, stack []string) error {
+	if node.Tag != IncludeTag {
+		for _, c := range node.Content {
+			err := resolveIncludes(c, dir, stack)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if node.Kind != yaml3.ScalarNode || node.Value == "" {
+		return fmt.Errorf("%s: line %d: %w: %s must be followed by a file path", stack[len(stack)-1], node.Line,
+			ErrInvalidInclude, IncludeTag)
+	}
+	pattern := node.Value
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	var included *yaml3.Node
+	if strings.ContainsAny(node.Value, "*?[") {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: line %d: %w: %s", stack[len(stack)-1], node.Line, ErrInvalidInclude, err)
+		}
+		sort.Strings(files)
+		included = &yaml3.Node{Kind: yaml3.SequenceNode, Tag: "!!seq"}
+		for _, f := range files {
+			n, err := readIncludeFile(f, stack)
+			if err != nil {
+				return err
+			}
+			if n == nil {
+				n = &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!null", Value: "null"}
+			}
+			included.Content = append(included.Content, n)
+		}
+	} else {
+		n, err := readIncludeFile(pattern, stack)
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			n = &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+		included = n
+	}
+	// Keep the comments around the include
+	included.HeadComment, included.LineComment = node.HeadComment, node.LineComment
+	*node = *included
+	return nil
+}
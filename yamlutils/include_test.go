@@ -0,0 +1,120 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "yamlutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(filename), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filename, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNewFromFileWithIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected interface{}
+		err      error
+	}{
+		{"no includes", map[string]string{"main.yml": "a: 1\n"}, map[interface{}]interface{}{"a": 1}, nil},
+		{"map", map[string]string{
+			"main.yml":    "app: api\ndatabase: !include conf/db.yml\n",
+			"conf/db.yml": "host: localhost\nport: 5432\n",
+		}, map[interface{}]interface{}{"app": "api", "database": map[interface{}]interface{}{"host": "localhost", "port": 5432}}, nil},
+		{"nested relative", map[string]string{
+			"main.yml":       "a: !include conf/a.yml\n",
+			"conf/a.yml":     "b: !include sub/b.yml\n",
+			"conf/sub/b.yml": "[1, 2]\n",
+		}, map[interface{}]interface{}{"a": map[interface{}]interface{}{"b": []interface{}{1, 2}}}, nil},
+		{"glob", map[string]string{
+			"main.yml":          "services: !include services/*.yml\nnone: !include missing/*.yml\n",
+			"services/b.yml":    "name: b\n",
+			"services/a.yml":    "name: a\n",
+			"services/skip.txt": "name: c\n",
+		}, map[interface{}]interface{}{"services": []interface{}{
+			map[interface{}]interface{}{"name": "a"},
+			map[interface{}]interface{}{"name": "b"},
+		}, "none": []interface{}{}}, nil},
+		{"list item", map[string]string{
+			"main.yml": "- !include a.yml\n- 2\n",
+			"a.yml":    "1\n",
+		}, []interface{}{1, 2}, nil},
+		{"root", map[string]string{
+			"main.yml": "!include a.yml\n",
+			"a.yml":    "a: 1\n",
+		}, map[interface{}]interface{}{"a": 1}, nil},
+		{"empty file", map[string]string{
+			"main.yml": "a: !include a.yml\n",
+			"a.yml":    "",
+		}, map[interface{}]interface{}{"a": nil}, nil},
+		{"missing file", map[string]string{"main.yml": "a: !include a.yml\n"}, nil, os.ErrNotExist},
+		{"cycle", map[string]string{
+			"main.yml": "a: !include a.yml\n",
+			"a.yml":    "b: !include main.yml\n",
+		}, nil, ErrIncludeCycle},
+		{"self", map[string]string{"main.yml": "a: !include main.yml\n"}, nil, ErrIncludeCycle},
+		{"invalid", map[string]string{"main.yml": "a: !include {b: 1}\n"}, nil, ErrInvalidInclude},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			defer os.RemoveAll(dir)
+			y, err := NewFromFileWithIncludes(filepath.Join(dir, "main.yml"))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if test.err != nil {
+				return
+			}
+			if !reflect.DeepEqual(y.Tree, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, y.Tree)
+			}
+		})
+	}
+}
+
+func TestIncludesSameFileTwice(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.yml": "a: !include c.yml\nb: !include c.yml\n",
+		"c.yml":    "x: 1\n",
+	})
+	defer os.RemoveAll(dir)
+	y, err := NewFromFileWithIncludes(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := map[interface{}]interface{}{
+		"a": map[interface{}]interface{}{"x": 1},
+		"b": map[interface{}]interface{}{"x": 1},
+	}
+	if !reflect.DeepEqual(y.Tree, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v\n", expected, y.Tree)
+	}
+}
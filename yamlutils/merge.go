@@ -0,0 +1,233 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ListStrategy - Defines how lists are merged.
+type ListStrategy string
+
+const (
+	// ListReplace - The overlay list replaces the base list, the default.
+	ListReplace ListStrategy = "replace"
+	// ListAppend - The overlay list items are appended to the base list.
+	ListAppend ListStrategy = "append"
+	// ListMergeByKey - Map items with the same value for the merge key are deep merged, other items are appended.
+	ListMergeByKey ListStrategy = "merge"
+)
+
+// DefaultMergeKey - Key used to match list items with ListMergeByKey when none is given.
+const DefaultMergeKey = "name"
+
+// ErrInvalidStrategy - The given list strategy is not supported.
+var ErrInvalidStrategy = fmt.Errorf("invalid list strategy")
+
+// ListMerge - Strategy used to merge a list.
+type ListMerge struct {
+	Strategy ListStrategy
+	// Key - Map key used to match list items with ListMergeByKey.
+	Key string
+}
+
+// MergeOptions - Options for Merge.
+type MergeOptions struct {
+	// Lists - Default strategy for lists, ListReplace when empty.
+	Lists ListMerge
+	// Paths - Strategy for the lists at the given paths.
+	// Paths are given in the form accepted by SplitPath, a '*' element matches any map key or list index.
+	// For example: "spec/template/spec/containers" or "services/*/ports".
+	// When several paths match, the one with less wildcards wins, then the one with the longest prefix without
+	// wildcards and then the lowest path string.
+	Paths map[string]ListMerge
+}
+
+// ParseListMerge parses a list strategy definition with the form: replace, append, merge or merge:<key>.
+func ParseListMerge(s string) (ListMerge, error) {
+	parts := strings.SplitN(s, ":", 2)
+	lm := ListMerge{Strategy: ListStrategy(parts[0])}
+	if len(parts) > 1 {
+		lm.Key = parts[1]
+	}
+	if lm.Strategy == "" || lm.validate() != nil || (lm.Strategy != ListMergeByKey && lm.Key != "") {
+		return lm, fmt.Errorf("%w: %s", ErrInvalidStrategy, s)
+	}
+	return lm, nil
+}
+
+func (lm ListMerge) validate() error {
+	switch lm.Strategy {
+	case "", ListReplace, ListAppend, ListMergeByKey:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidStrategy, lm.Strategy)
+}
+
+// Merge returns a new YML object with the deep merge of overlay on top of base.
+// Maps are merged recursively, lists are merged following the strategy defined in opts and any other overlay value
+// replaces the base value.
+// Like Helm values, a null overlay value removes the key from the base map and an empty or null overlay document
+// leaves base unchanged.
+// The inputs are not modified.
+// The result keeps the source and options of base so its format can be preserved.
+func Merge(base, overlay *YML, opts MergeOptions) (*YML, error) {
+	for p, lm := range opts.Paths {
+		if err := lm.validate(); err != nil {
+			return nil, fmt.Errorf("path '%s': %w", p, err)
+		}
+	}
+	if err := opts.Lists.validate(); err != nil {
+		return nil, err
+	}
+	m := merger{opts: opts}
+	tree := deepCopy(base.Tree)
+	if overlay.Tree != nil {
+		tree = m.merge(base.Tree, overlay.Tree, []string{})
+	}
+	return &YML{Tree: tree, PreserveFormat: base.PreserveFormat, Format: base.Format, source: base.source}, nil
+}
+
+type merger struct {
+	opts MergeOptions
+}
+
+// listMerge returns the strategy for the list at the given path.
+func (m merger) listMerge(path []string) ListMerge {
+	lm := m.opts.Lists
+	// The most specific pattern, the one with less wildcards, wins.
+	// Ties are broken by the longest prefix without wildcards and then by the pattern string.
+	best := ""
+	wildcards, prefix := -1, 0
+	for p, plm := range m.opts.Paths {
+		pattern := SplitPath(p)
+		if !pathMatches(pattern, path) {
+			continue
+		}
+		n, pre := 0, len(pattern)
+		for i, e := range pattern {
+			if e == Wildcard {
+				n++
+				if pre == len(pattern) {
+					pre = i
+				}
+			}
+		}
+		if wildcards == -1 || n < wildcards || n == wildcards && (pre > prefix || pre == prefix && p < best) {
+			lm, wildcards, prefix, best = plm, n, pre, p
+		}
+	}
+	if lm.Strategy == "" {
+		lm.Strategy = ListReplace
+	}
+	if lm.Strategy == ListMergeByKey && lm.Key == "" {
+		lm.Key = DefaultMergeKey
+	}
+	return lm
+}
+
+// pathMatches returns true when the concrete path matches the pattern, a '*' pattern element matches any element.
+func pathMatches(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] == Wildcard {
+			continue
+		}
+		e, _ := literalKey(pattern[i])
+		if k, _ := literalKey(path[i]); e != k {
+			return false
+		}
+	}
+	return true
+}
+
+func (m merger) merge(base, overlay interface{}, path []string) interface{} {
+	switch o := overlay.(type) {
+	case map[interface{}]interface{}:
+		b, ok := base.(map[interface{}]interface{})
+		if !ok {
+			return deepCopy(overlay)
+		}
+		r := deepCopy(b).(map[interface{}]interface{})
+		for k, v := range o {
+			if v == nil {
+				delete(r, k)
+				continue
+			}
+			if bv, ok := b[k]; ok {
+				r[k] = m.merge(bv, v, appendPath(path, pathKey(k)))
+				continue
+			}
+			r[k] = deepCopy(v)
+		}
+		return r
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return deepCopy(overlay)
+		}
+		lm := m.listMerge(path)
+		Logger.Printf("merge: list '%s' strategy %s", JoinPath(path), lm.Strategy)
+		switch lm.Strategy {
+		case ListAppend:
+			r := deepCopy(b).([]interface{})
+			return append(r, deepCopy(o).([]interface{})...)
+		case ListMergeByKey:
+			r := deepCopy(b).([]interface{})
+			for _, v := range o {
+				index := -1
+				if ov, ok := v.(map[interface{}]interface{}); ok {
+					if key, ok := ov[lm.Key]; ok {
+						for i, bv := range r {
+							if bm, ok := bv.(map[interface{}]interface{}); ok && reflect.DeepEqual(bm[lm.Key], key) {
+								index = i
+								break
+							}
+						}
+					}
+				}
+				if index == -1 {
+					r = append(r, deepCopy(v))
+					continue
+				}
+				r[index] = m.merge(r[index], v, appendPath(path, strconv.Itoa(index)))
+			}
+			return r
+		default:
+			return deepCopy(overlay)
+		}
+	default:
+		return overlay
+	}
+}
+
+// deepCopy returns a copy of the tree that doesn't share maps or lists with the original.
+func deepCopy(m interface{}) interface{} {
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		r := make(map[interface{}]interface{}, len(t))
+		for k, v := range t {
+			r[k] = deepCopy(v)
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(t))
+		for i, v := range t {
+			r[i] = deepCopy(v)
+		}
+		return r
+	default:
+		return m
+	}
+}
@@ -0,0 +1,265 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseListMerge(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ListMerge
+		err      error
+	}{
+		{"replace", ListMerge{ListReplace, ""}, nil},
+		{"append", ListMerge{ListAppend, ""}, nil},
+		{"merge", ListMerge{ListMergeByKey, ""}, nil},
+		{"merge:id", ListMerge{ListMergeByKey, "id"}, nil},
+		{"append:id", ListMerge{ListAppend, "id"}, ErrInvalidStrategy},
+		{"", ListMerge{}, ErrInvalidStrategy},
+		{"concat", ListMerge{"concat", ""}, ErrInvalidStrategy},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			output, err := ParseListMerge(test.input)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := `image:
+  name: app
+  tag: "1.0"
+replicas: 1
+debug: true
+hosts:
+  - a.example.com
+containers:
+  - name: api
+    env:
+      - name: A
+        value: "1"
+  - name: worker
+`
+	tests := []struct {
+		name     string
+		overlay  string
+		opts     MergeOptions
+		expected string
+		err      error
+	}{
+		{"scalars and maps", `image:
+  tag: "2.0"
+replicas: 3
+debug: null
+`, MergeOptions{}, `containers:
+- env:
+  - name: A
+    value: "1"
+  name: api
+- name: worker
+hosts:
+- a.example.com
+image:
+  name: app
+  tag: "2.0"
+replicas: 3
+`, nil},
+		{"list replace", `hosts: [b.example.com]`, MergeOptions{}, `containers:
+- env:
+  - name: A
+    value: "1"
+  name: api
+- name: worker
+debug: true
+hosts:
+- b.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"list append", `hosts: [b.example.com]`, MergeOptions{Lists: ListMerge{Strategy: ListAppend}}, `containers:
+- env:
+  - name: A
+    value: "1"
+  name: api
+- name: worker
+debug: true
+hosts:
+- a.example.com
+- b.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"list merge by key", `containers:
+  - name: api
+    image: api:2.0
+    env:
+      - name: B
+        value: "2"
+  - name: cron
+`, MergeOptions{Paths: map[string]ListMerge{
+			"containers":         {Strategy: ListMergeByKey},
+			"containers/*/env":   {Strategy: ListAppend},
+			"containers/0/other": {Strategy: ListReplace},
+		}}, `containers:
+- env:
+  - name: A
+    value: "1"
+  - name: B
+    value: "2"
+  image: api:2.0
+  name: api
+- name: worker
+- name: cron
+debug: true
+hosts:
+- a.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"empty overlay", ``, MergeOptions{}, `containers:
+- env:
+  - name: A
+    value: "1"
+  name: api
+- name: worker
+debug: true
+hosts:
+- a.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"comment only overlay", "# nothing to override\n", MergeOptions{}, `containers:
+- env:
+  - name: A
+    value: "1"
+  name: api
+- name: worker
+debug: true
+hosts:
+- a.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"null overlay", `null`, MergeOptions{}, `containers:
+- env:
+  - name: A
+    value: "1"
+  name: api
+- name: worker
+debug: true
+hosts:
+- a.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"same number of wildcards", `containers:
+  - name: api
+    env:
+      - name: B
+`, MergeOptions{Paths: map[string]ListMerge{
+			"containers":       {Strategy: ListMergeByKey},
+			"containers/*/env": {Strategy: ListAppend},
+			"containers/0/*":   {Strategy: ListReplace},
+		}}, `containers:
+- env:
+  - name: B
+  name: api
+- name: worker
+debug: true
+hosts:
+- a.example.com
+image:
+  name: app
+  tag: "1.0"
+replicas: 1
+`, nil},
+		{"invalid strategy", `a: 1`, MergeOptions{Paths: map[string]ListMerge{"hosts": {Strategy: "x"}}}, ``, ErrInvalidStrategy},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			b, err := NewFromString(base)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			o, err := NewFromString(test.overlay)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			baseTree := deepCopy(b.Tree)
+			merged, err := Merge(b, o, test.opts)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(b.Tree, baseTree) {
+				t.Errorf("Base modified:\n%#v\n", b.Tree)
+			}
+			if err != nil {
+				return
+			}
+			output, err := merged.GetString(false, []string{})
+			if err != nil {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+			t.Log(buf.String())
+		})
+	}
+}
+
+func TestMergePreserveFormat(t *testing.T) {
+	b, err := NewFromString("# Base\nimage:\n  tag: \"1.0\" # pinned\nreplicas: 1\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	b.PreserveFormat = true
+	o, err := NewFromString("image:\n  tag: \"2.0\"\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	merged, err := Merge(b, o, MergeOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	output, err := merged.Document()
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	expected := "# Base\nimage:\n  tag: \"2.0\" # pinned\nreplicas: 1\n"
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+}
@@ -0,0 +1,419 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch - The patch document is not valid.
+var ErrInvalidPatch = fmt.Errorf("invalid patch")
+
+// ErrInvalidPointer - The JSON Pointer is not valid.
+var ErrInvalidPointer = fmt.Errorf("invalid JSON pointer")
+
+// ErrTestFailed - A JSON Patch test operation didn't match.
+var ErrTestFailed = fmt.Errorf("test failed")
+
+// PatchOperation - RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	// Op - One of add, remove, replace, move, copy or test.
+	Op string
+	// Path - JSON Pointer (RFC 6901) to the target location.
+	Path string
+	// From - JSON Pointer to the source location of move and copy.
+	From string
+	// Value - Value for add, replace and test.
+	Value interface{}
+}
+
+// ParsePatch returns the operations of an RFC 6902 JSON Patch document given as a YAML or JSON tree.
+func ParsePatch(patch interface{}) ([]PatchOperation, error) {
+	list, ok := patch.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: must be a list of operations", ErrInvalidPatch)
+	}
+	ops := make([]PatchOperation, len(list))
+	for i, e := range list {
+		m, ok := e.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: operation %d: must be a map", ErrInvalidPatch, i)
+		}
+		member := func(name string, required bool) (string, error) {
+			v, ok := m[name]
+			if !ok {
+				if required {
+					return "", fmt.Errorf("%w: operation %d: missing '%s'", ErrInvalidPatch, i, name)
+				}
+				return "", nil
+			}
+			s, ok := v.(string)
+			if !ok {
+				return "", fmt.Errorf("%w: operation %d: '%s' must be a string", ErrInvalidPatch, i, name)
+			}
+			return s, nil
+		}
+		var err error
+		ops[i].Op, err = member("op", true)
+		if err != nil {
+			return nil, err
+		}
+		ops[i].Path, err = member("path", true)
+		if err != nil {
+			return nil, err
+		}
+		switch ops[i].Op {
+		case "add", "replace", "test":
+			if _, ok := m["value"]; !ok {
+				return nil, fmt.Errorf("%w: operation %d: missing 'value'", ErrInvalidPatch, i)
+			}
+			ops[i].Value = m["value"]
+		case "move", "copy":
+			ops[i].From, err = member("from", true)
+			if err != nil {
+				return nil, err
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d: unknown op '%s'", ErrInvalidPatch, i, ops[i].Op)
+		}
+	}
+	return ops, nil
+}
+
+// Patch applies the RFC 6902 JSON Patch document to the YML tree.
+// The patch can be loaded from YAML or JSON.
+// The patch is applied atomically, if any operation fails, including a test operation, the tree is left untouched.
+// Returns the resulting document.
+func (y *YML) Patch(patch *YML) (string, error) {
+	ops, err := ParsePatch(patch.Tree)
+	if err != nil {
+		return "", err
+	}
+	tree, err := ApplyPatch(y.Tree, ops)
+	if err != nil {
+		return "", err
+	}
+	y.Tree = tree
+	out, err := y.marshalTree()
+	return string(out), err
+}
+
+// MergePatch applies the RFC 7386 JSON Merge Patch document to the YML tree.
+// The patch can be loaded from YAML or JSON.
+// Returns the resulting document.
+func (y *YML) MergePatch(patch *YML) (string, error) {
+	y.Tree = ApplyMergePatch(y.Tree, patch.Tree)
+	out, err := y.marshalTree()
+	return string(out), err
+}
+
+// ApplyPatch returns a copy of the tree with the operations applied.
+// The tree is not modified.
+func ApplyPatch(tree interface{}, ops []PatchOperation) (interface{}, error) {
+	tree = deepCopy(tree)
+	for i, op := range ops {
+		Logger.Printf("patch: %s %s", op.Op, op.Path)
+		var err error
+		tree, err = applyOperation(tree, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return tree, nil
+}
+
+func applyOperation(tree interface{}, op PatchOperation) (interface{}, error) {
+	p, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return pointerAdd(tree, p, deepCopy(op.Value))
+	case "remove":
+		tree, _, err = pointerRemove(tree, p)
+		return tree, err
+	case "replace":
+		tree, _, err = pointerRemove(tree, p)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(tree, p, deepCopy(op.Value))
+	case "move", "copy":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if op.Op == "move" {
+			if len(from) < len(p) && pointerHasPrefix(p, from) {
+				return nil, fmt.Errorf("%w: can't move '%s' into one of its children", ErrInvalidPatch, op.From)
+			}
+			tree, value, err = pointerRemove(tree, from)
+		} else {
+			value, err = pointerGet(tree, from)
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(tree, p, value)
+	case "test":
+		value, err := pointerGet(tree, p)
+		if err != nil {
+			return nil, err
+		}
+		if !valuesEqual(JSONCompatible(value), JSONCompatible(op.Value)) {
+			return nil, fmt.Errorf("%w: expected %s, got %s", ErrTestFailed, diffValue(op.Value), diffValue(value))
+		}
+		return tree, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op '%s'", ErrInvalidPatch, op.Op)
+}
+
+// ParsePointer returns the path elements of an RFC 6901 JSON Pointer.
+// The empty pointer references the whole document.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPointer, pointer)
+	}
+	p := strings.Split(pointer[1:], "/")
+	for i, e := range p {
+		p[i] = strings.ReplaceAll(strings.ReplaceAll(e, "~1", "/"), "~0", "~")
+	}
+	return p, nil
+}
+
+var pointerIndexRegex = regexp.MustCompile(`^(0|[1-9]\d*)$`)
+
+// pointerIndex returns the list index for the path element.
+// '-' references the end of the list and is only valid when end is true.
+func pointerIndex(list []interface{}, e string, end bool) (int, error) {
+	if e == "-" && end {
+		return len(list), nil
+	}
+	if !pointerIndexRegex.MatchString(e) {
+		return 0, fmt.Errorf("%w: %s", ErrNotAnIndex, e)
+	}
+	i, err := strconv.Atoi(e)
+	if err != nil || i > len(list) || (i == len(list) && !end) {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidIndex, e)
+	}
+	return i, nil
+}
+
+// pointerUpdate calls fn with the parent of the element referenced by p and returns the tree with the parent
+// replaced by the result of fn.
+func pointerUpdate(tree interface{}, p []string, fn func(parent interface{}, e string) (interface{}, error)) (interface{}, error) {
+	if len(p) == 1 {
+		return fn(tree, p[0])
+	}
+	switch t := tree.(type) {
+	case map[interface{}]interface{}:
+		k, v, ok := mapKey(t, p[0])
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMapKeyNotFound, p[0])
+		}
+		child, err := pointerUpdate(v, p[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		t[k] = child
+		return t, nil
+	case []interface{}:
+		i, err := pointerIndex(t, p[0], false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := pointerUpdate(t[i], p[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		t[i] = child
+		return t, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrExtraElementsInPath, p[0])
+}
+
+func pointerGet(tree interface{}, p []string) (interface{}, error) {
+	for _, e := range p {
+		switch t := tree.(type) {
+		case map[interface{}]interface{}:
+			_, v, ok := mapKey(t, e)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrMapKeyNotFound, e)
+			}
+			tree = v
+		case map[string]interface{}:
+			v, ok := t[e]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrMapKeyNotFound, e)
+			}
+			tree = v
+		case []interface{}:
+			i, err := pointerIndex(t, e, false)
+			if err != nil {
+				return nil, err
+			}
+			tree = t[i]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrExtraElementsInPath, e)
+		}
+	}
+	return tree, nil
+}
+
+func pointerAdd(tree interface{}, p []string, value interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(tree, p, func(parent interface{}, e string) (interface{}, error) {
+		switch t := parent.(type) {
+		case map[interface{}]interface{}:
+			k, _, _ := mapKey(t, e)
+			t[k] = value
+			return t, nil
+		case []interface{}:
+			i, err := pointerIndex(t, e, true)
+			if err != nil {
+				return nil, err
+			}
+			t = append(t, nil)
+			copy(t[i+1:], t[i:])
+			t[i] = value
+			return t, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidParentType, e)
+	})
+}
+
+func pointerRemove(tree interface{}, p []string) (interface{}, interface{}, error) {
+	if len(p) == 0 {
+		return nil, tree, nil
+	}
+	var removed interface{}
+	tree, err := pointerUpdate(tree, p, func(parent interface{}, e string) (interface{}, error) {
+		switch t := parent.(type) {
+		case map[interface{}]interface{}:
+			k, v, ok := mapKey(t, e)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrMapKeyNotFound, e)
+			}
+			removed = v
+			delete(t, k)
+			return t, nil
+		case []interface{}:
+			i, err := pointerIndex(t, e, false)
+			if err != nil {
+				return nil, err
+			}
+			removed = t[i]
+			return append(t[:i], t[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrExtraElementsInPath, e)
+	})
+	return tree, removed, err
+}
+
+// valuesEqual compares JSON compatible trees, numbers are equal when their values are equal regardless of their type.
+func valuesEqual(a, b interface{}) bool {
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for k, v := range at {
+			bv, ok := bt[k]
+			if !ok || !valuesEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !valuesEqual(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	af, aOk := toFloat(a)
+	bf, bOk := toFloat(b)
+	if aOk || bOk {
+		return aOk && bOk && af == bf
+	}
+	return a == b
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float64:
+		return t, true
+	}
+	return 0, false
+}
+
+// ApplyMergePatch returns the result of applying the RFC 7386 JSON Merge Patch to the tree.
+// Maps are merged recursively, null patch values remove keys and any other value replaces the target.
+// The tree is not modified.
+func ApplyMergePatch(tree, patch interface{}) interface{} {
+	p, ok := patch.(map[interface{}]interface{})
+	if !ok {
+		return deepCopy(patch)
+	}
+	t, ok := tree.(map[interface{}]interface{})
+	if !ok {
+		t = map[interface{}]interface{}{}
+	}
+	r := deepCopy(t).(map[interface{}]interface{})
+	for k, v := range p {
+		key, current, ok := mapKey(r, KeyString(k))
+		if !ok {
+			key = k
+		}
+		if v == nil {
+			delete(r, key)
+			continue
+		}
+		r[key] = ApplyMergePatch(current, v)
+	}
+	return r
+}
+
+// pointerHasPrefix returns true when the first elements of p are the elements of prefix.
+// Elements are compared literally, JSON Pointers have no wildcards.
+func pointerHasPrefix(p, prefix []string) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		err      error
+	}{
+		{"", []string{}, nil},
+		{"/", []string{""}, nil},
+		{"/a/b", []string{"a", "b"}, nil},
+		{"/a~1b/m~0n", []string{"a/b", "m~n"}, nil},
+		{"/~01", []string{"~1"}, nil},
+		{"a/b", nil, ErrInvalidPointer},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			output, err := ParsePointer(test.input)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+		err      error
+	}{
+		{"add map key", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			"baz: qux\nfoo: bar\n", nil},
+		{"add list item", `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			"foo:\n- bar\n- qux\n- baz\n", nil},
+		{"add to end", `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": "qux"}]`,
+			"foo:\n- bar\n- qux\n", nil},
+		{"add replaces key", `{"foo": "bar"}`, `[{"op": "add", "path": "/foo", "value": [1]}]`,
+			"foo:\n- 1\n", nil},
+		{"add nested missing parent", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			"", ErrMapKeyNotFound},
+		{"add out of bounds", `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/2", "value": "qux"}]`,
+			"", ErrInvalidIndex},
+		{"remove map key", `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`,
+			"foo: bar\n", nil},
+		{"remove list item", `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`,
+			"foo:\n- bar\n- baz\n", nil},
+		{"remove missing", `{"foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`,
+			"", ErrMapKeyNotFound},
+		{"replace", `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			"baz: boo\nfoo: bar\n", nil},
+		{"replace missing", `{"foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			"", ErrMapKeyNotFound},
+		{"replace root", `{"foo": "bar"}`, `[{"op": "replace", "path": "", "value": {"a": 1}}]`,
+			"a: 1\n", nil},
+		{"move", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			"foo:\n  bar: baz\nqux:\n  corge: grault\n  thud: fred\n", nil},
+		{"move list item", `{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			"foo:\n- all\n- cows\n- eat\n- grass\n", nil},
+		{"move into child", `{"foo": {"bar": 1}}`, `[{"op": "move", "from": "/foo", "path": "/foo/bar/x"}]`,
+			"", ErrInvalidPatch},
+		{"move wildcard key", `{"*": 1, "a": {"c": 2}}`, `[{"op": "move", "from": "/*", "path": "/a/b"}]`,
+			"a:\n  b: 1\n  c: 2\n", nil},
+		{"copy", `{"foo": {"bar": 1}}`, `[{"op": "copy", "from": "/foo", "path": "/baz"}]`,
+			"baz:\n  bar: 1\nfoo:\n  bar: 1\n", nil},
+		{"test", `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`,
+			"baz: qux\nfoo:\n- a\n- 2\n- c\n", nil},
+		{"test failed", `{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			"", ErrTestFailed},
+		{"test number and string", `{"baz": 1}`, `[{"op": "test", "path": "/baz", "value": "1"}]`,
+			"", ErrTestFailed},
+		{"escaped pointer", `{"a/b": {"m~n": 1}}`, `[{"op": "replace", "path": "/a~1b/m~0n", "value": 2}]`,
+			"a/b:\n  m~n: 2\n", nil},
+		{"not an index", `{"foo": ["bar"]}`, `[{"op": "replace", "path": "/foo/01", "value": 2}]`,
+			"", ErrNotAnIndex},
+		{"yaml patch", "foo: bar\n", "- op: add\n  path: /baz\n  value: {a: 1}\n",
+			"baz:\n  a: 1\nfoo: bar\n", nil},
+		{"invalid op", `{"foo": "bar"}`, `[{"op": "update", "path": "/foo"}]`, "", ErrInvalidPatch},
+		{"missing value", `{"foo": "bar"}`, `[{"op": "add", "path": "/foo"}]`, "", ErrInvalidPatch},
+		{"not a list", `{"foo": "bar"}`, `{"op": "add", "path": "/foo", "value": 1}`, "", ErrInvalidPatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y, err := NewFromString(test.doc)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			patch, err := NewFromString(test.patch)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output, err := y.Patch(patch)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestPatchAtomic(t *testing.T) {
+	y, _ := NewFromString("a: 1\nl: [1, 2]\n")
+	patch, _ := NewFromString(`[
+  {"op": "replace", "path": "/a", "value": 2},
+  {"op": "remove", "path": "/l/0"},
+  {"op": "test", "path": "/a", "value": 3}
+]`)
+	_, err := y.Patch(patch)
+	if !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := map[interface{}]interface{}{"a": 1, "l": []interface{}{1, 2}}
+	if !reflect.DeepEqual(y.Tree, expected) {
+		t.Errorf("Tree was modified:\n%#v\n", y.Tree)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7386 Appendix A
+	tests := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, "a: c\n"},
+		{`{"a":"b"}`, `{"b":"c"}`, "a: b\nb: c\n"},
+		{`{"a":"b"}`, `{"a":null}`, "{}\n"},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, "b: c\n"},
+		{`{"a":["b"]}`, `{"a":"c"}`, "a: c\n"},
+		{`{"a":"c"}`, `{"a":["b"]}`, "a:\n- b\n"},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, "a:\n  b: d\n"},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, "a:\n- 1\n"},
+		{`["a","b"]`, `["c","d"]`, "- c\n- d\n"},
+		{`{"a":"b"}`, `["c"]`, "- c\n"},
+		{`{"a":"foo"}`, `null`, "null\n"},
+		{`{"a":"foo"}`, `"bar"`, "bar\n"},
+		{`{"e":null}`, `{"a":1}`, "a: 1\ne: null\n"},
+		{`[1,2]`, `{"a":"b","c":null}`, "a: b\n"},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, "a:\n  bb: {}\n"},
+		{"1: a\n", "1: b\n", "1: b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.doc+" "+test.patch, func(t *testing.T) {
+			y, err := NewFromString(test.doc)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			patch, err := NewFromString(test.patch)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output, err := y.MergePatch(patch)
+			if err != nil {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
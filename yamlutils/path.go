@@ -0,0 +1,525 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Wildcard - Path element that matches every map value or list item.
+	Wildcard = "*"
+	// RecursiveWildcard - Path element that matches the current element and all its descendants.
+	RecursiveWildcard = "**"
+)
+
+// ErrNoMatch - No list item matches the filter.
+var ErrNoMatch = fmt.Errorf("no match")
+
+// ErrMultipleMatches - More than one list item matches the filter when a single element is required.
+var ErrMultipleMatches = fmt.Errorf("multiple matches")
+
+// Match - Element found in the tree with its concrete path.
+type Match struct {
+	Path  []string
+	Value interface{}
+}
+
+// HasWildcard returns true when the path has elements that can match more than one element.
+// Literal keys, see LiteralKey, are never wildcards.
+func HasWildcard(p []string) bool {
+	for _, e := range p {
+		if e == Wildcard || e == RecursiveWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAll returns all the elements in the tree that match the path.
+// Besides map keys and list indexes, the path can contain the Wildcard '*' to fan out over every map value or
+// list item and the RecursiveWildcard '**' to descend recursively.
+// List filters, for example '[name=api]', and slices, for example '1:3', match every list item they select.
+// Map values are visited in sorted key order.
+// Path elements that don't match are skipped, if there are no matches the first error found is returned.
+func FindAll(m interface{}, p []string) ([]Match, error) {
+	Logger.Printf("FindAll: Input path: '%s'", pathString(p))
+	matches, err := findAll(m, p, []string{})
+	if len(matches) > 0 {
+		return matches, nil
+	}
+	return matches, err
+}
+
+func findAll(m interface{}, p []string, prefix []string) ([]Match, error) {
+	if len(p) <= 0 {
+		return []Match{{Path: prefix, Value: m}}, nil
+	}
+	matches := []Match{}
+	var firstErr error
+	collect := func(mm []Match, err error) {
+		matches = append(matches, mm...)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	switch p[0] {
+	case Wildcard:
+		for _, c := range children(m) {
+			collect(findAll(c.Value, p[1:], appendPath(prefix, c.Path[0])))
+		}
+		return matches, firstErr
+	case RecursiveWildcard:
+		// Zero levels
+		collect(findAll(m, p[1:], prefix))
+		// One or more levels
+		for _, c := range children(m) {
+			collect(findAll(c.Value, p, appendPath(prefix, c.Path[0])))
+		}
+		return matches, firstErr
+	}
+	if t, isList := m.([]interface{}); isList {
+		if start, end, ok := parseSlice(p[0], len(t)); ok {
+			for i := start; i < end; i++ {
+				collect(findAll(t[i], p[1:], appendPath(prefix, strconv.Itoa(i))))
+			}
+			return matches, firstErr
+		}
+	}
+	if f, ok := parseFilter(p[0]); ok {
+		if t, isList := m.([]interface{}); isList {
+			for i, v := range t {
+				if f.match(v) {
+					collect(findAll(v, p[1:], appendPath(prefix, strconv.Itoa(i))))
+				}
+			}
+			if len(matches) == 0 && firstErr == nil {
+				firstErr = fmt.Errorf("%w: %s", ErrNoMatch, pathElement(p[0]))
+			}
+			return matches, firstErr
+		}
+	}
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		key, e, ok := mapKey(t, p[0])
+		if !ok {
+			return matches, fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(p[0]))
+		}
+		return findAll(e, p[1:], appendPath(prefix, pathKey(key)))
+	case []interface{}:
+		index, err := listIndex(t, p[0])
+		if err != nil {
+			return matches, err
+		}
+		return findAll(t[index], p[1:], appendPath(prefix, strconv.Itoa(index)))
+	default:
+		return matches, fmt.Errorf("%w: %s", ErrExtraElementsInPath, pathString(p))
+	}
+}
+
+// children returns the direct children of the element with their single element path.
+func children(m interface{}) []Match {
+	switch t := m.(type) {
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(t))
+		values := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			key := pathKey(k)
+			keys = append(keys, key)
+			values[key] = v
+		}
+		sort.Strings(keys)
+		r := make([]Match, len(keys))
+		for i, k := range keys {
+			r[i] = Match{Path: []string{k}, Value: values[k]}
+		}
+		return r
+	case []interface{}:
+		r := make([]Match, len(t))
+		for i, v := range t {
+			r[i] = Match{Path: []string{strconv.Itoa(i)}, Value: v}
+		}
+		return r
+	}
+	return nil
+}
+
+func appendPath(prefix []string, e string) []string {
+	r := make([]string, len(prefix), len(prefix)+1)
+	copy(r, prefix)
+	return append(r, e)
+}
+
+// GetMatches returns all the elements that match the path, see FindAll.
+func (y *YML) GetMatches(keys []string) ([]Match, error) {
+	return FindAll(y.Tree, keys)
+}
+
+// GetMatchesString returns a list of all the elements that match the path, see FindAll.
+// Each list item is a map with the concrete path of the element, joined with JoinPath, and its value.
+func (y *YML) GetMatchesString(keys []string) (string, error) {
+	path := strings.Join(pathElements(keys), ",")
+	matches, errPath := FindAll(y.Tree, keys)
+	list := []interface{}{}
+	for _, m := range matches {
+		list = append(list, map[interface{}]interface{}{"path": JoinPath(m.Path), "value": m.Value})
+	}
+	out, err := y.marshal(list)
+	if errPath != nil {
+		return string(out), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
+	}
+	if err != nil {
+		return string(out), fmt.Errorf("failed to Marshal output: %w", err)
+	}
+	return string(out), nil
+}
+
+// filter - List item selector with the form '[key<op>value]', for example: [name=api] or [port>=8080].
+type filter struct {
+	key   string
+	op    string
+	value string
+}
+
+// filterOperators - Ordered so that two character operators are matched first.
+var filterOperators = []string{"!=", "<=", ">=", "=", "<", ">"}
+
+// parseFilter returns the filter represented by the path element.
+// The value can be optionally quoted with single or double quotes.
+func parseFilter(e string) (filter, bool) {
+	if len(e) < 2 || e[0] != '[' || e[len(e)-1] != ']' {
+		return filter{}, false
+	}
+	inner := e[1 : len(e)-1]
+	pos, op := -1, ""
+	for _, o := range filterOperators {
+		if i := strings.Index(inner, o); i > 0 && (pos == -1 || i < pos) {
+			pos, op = i, o
+		}
+	}
+	if pos == -1 {
+		return filter{}, false
+	}
+	key := strings.TrimSpace(inner[:pos])
+	value := strings.TrimSpace(inner[pos+len(op):])
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return filter{key: key, op: op, value: value}, true
+}
+
+// match returns true when the element is a map with the filter key and its value satisfies the filter.
+// Numerical values are compared as numbers, any other value is compared by its string representation.
+func (f filter) match(m interface{}) bool {
+	t, ok := m.(map[interface{}]interface{})
+	if !ok {
+		return false
+	}
+	_, v, ok := mapKey(t, f.key)
+	if !ok {
+		return false
+	}
+	str := fmt.Sprintf("%v", v)
+	if v == nil {
+		str = "null"
+	}
+	cmp := strings.Compare(str, f.value)
+	switch v.(type) {
+	case int, int64, uint64, float64:
+		n, err := strconv.ParseFloat(str, 64)
+		fv, errValue := strconv.ParseFloat(f.value, 64)
+		if err == nil && errValue == nil {
+			switch {
+			case n < fv:
+				cmp = -1
+			case n > fv:
+				cmp = 1
+			default:
+				cmp = 0
+			}
+		}
+	}
+	switch f.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// listIndex returns the index of the list item designated by the path element.
+// The element can be a numerical index or a filter matching a single list item.
+// Negative indexes count from the end of the list, -1 is the last item.
+func listIndex(list []interface{}, e string) (int, error) {
+	if f, ok := parseFilter(e); ok {
+		index := -1
+		for i, v := range list {
+			if f.match(v) {
+				if index != -1 {
+					return 0, fmt.Errorf("%w: %s", ErrMultipleMatches, e)
+				}
+				index = i
+			}
+		}
+		if index == -1 {
+			return 0, fmt.Errorf("%w: %s", ErrNoMatch, e)
+		}
+		return index, nil
+	}
+	index, err := strconv.Atoi(e)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotAnIndex, pathElement(e))
+	}
+	if index < 0 {
+		index += len(list)
+	}
+	if index < 0 || len(list) <= index {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidIndex, e)
+	}
+	return index, nil
+}
+
+var sliceRegex = regexp.MustCompile(`^(-?\d+)?:(-?\d+)?$`)
+
+// parseSlice returns the range of items selected by a 'start:end' path element in a list of length n.
+// Both start and end are optional and can be negative to count from the end of the list.
+// Out of range values are adjusted to the list boundaries.
+func parseSlice(e string, n int) (int, int, bool) {
+	parts := sliceRegex.FindStringSubmatch(e)
+	if parts == nil {
+		return 0, 0, false
+	}
+	bound := func(s string, def int) int {
+		if s == "" {
+			return def
+		}
+		i, _ := strconv.Atoi(s)
+		if i < 0 {
+			i += n
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > n {
+			return n
+		}
+		return i
+	}
+	start := bound(parts[1], 0)
+	end := bound(parts[2], n)
+	if end < start {
+		end = start
+	}
+	return start, end, true
+}
+
+// literalMark - Prefix of path elements that only match map keys, see LiteralKey.
+const literalMark = "\x00"
+
+// LiteralKey returns a path element that only matches the map key k, even when k looks like a wildcard, a list
+// filter, a slice or an index.
+// SplitPath returns escaped and quoted elements as literal keys and JoinPath escapes them.
+func LiteralKey(k string) string {
+	if strings.HasPrefix(k, literalMark) {
+		return k
+	}
+	return literalMark + k
+}
+
+// literalKey returns the map key of the path element and true when the element is a literal key.
+func literalKey(e string) (string, bool) {
+	if strings.HasPrefix(e, literalMark) {
+		return e[len(literalMark):], true
+	}
+	return e, false
+}
+
+// pathKey returns the path element for the map key k.
+// Keys that would be parsed as a wildcard, a list filter or a slice are returned as literal keys.
+func pathKey(k interface{}) string {
+	key := KeyString(k)
+	if isPattern(key) {
+		return LiteralKey(key)
+	}
+	return key
+}
+
+// isPattern returns true when the path element is a wildcard, a list filter or a slice.
+func isPattern(e string) bool {
+	if e == Wildcard || e == RecursiveWildcard || sliceRegex.MatchString(e) {
+		return true
+	}
+	_, ok := parseFilter(e)
+	return ok
+}
+
+// pathString returns the path as shown in messages.
+func pathString(p []string) string {
+	return strings.Join(pathElements(p), string(PathSeparator))
+}
+
+// pathElements returns the path elements as shown in messages.
+func pathElements(p []string) []string {
+	elements := make([]string, len(p))
+	for i, e := range p {
+		elements[i] = pathElement(e)
+	}
+	return elements
+}
+
+// pathElement returns the path element as shown in messages, literal keys are escaped as in JoinPath.
+func pathElement(e string) string {
+	if _, literal := literalKey(e); literal {
+		return JoinPath([]string{e})
+	}
+	return e
+}
+
+// KeyString returns the string representation of a map key as used in paths.
+func KeyString(k interface{}) string {
+	if k == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%v", k)
+}
+
+// mapKey returns the actual map key matching the path element together with its value.
+// String keys are matched first, then integer, float, boolean and null keys are matched by their string
+// representation, null keys also match '~'.
+// When there is no match, the path element is returned as the key.
+func mapKey(m map[interface{}]interface{}, e string) (interface{}, interface{}, bool) {
+	e, _ = literalKey(e)
+	if v, ok := m[e]; ok {
+		return e, v, true
+	}
+	for k, v := range m {
+		switch k.(type) {
+		case string:
+			continue
+		case nil:
+			if e == "null" || e == "~" {
+				return k, v, true
+			}
+		default:
+			if KeyString(k) == e {
+				return k, v, true
+			}
+		}
+	}
+	return e, nil, false
+}
+
+// PathSeparator - Separator used between path elements by SplitPath and JoinPath.
+const PathSeparator = '/'
+
+// SplitPath splits a path string into its elements.
+// Elements are separated by '/'.
+// A separator can be escaped with a backslash, '\/', or the element can be quoted with single or double quotes.
+// A literal backslash is written as '\\'.
+// Separators inside list filters, for example '[url=http://example.com]', don't need escaping.
+// For example: metadata/labels/"app.kubernetes.io/name"
+// Quoted elements and elements with escaped characters outside of list filters that would otherwise be a wildcard,
+// a list filter, a slice or a list index are returned as literal keys, see LiteralKey, so '\*', '"1:3"' and '\[a=b]'
+// only match map keys with that name.
+func SplitPath(path string) []string {
+	elements := []string{}
+	var b strings.Builder
+	var quote rune
+	brackets := 0
+	escaped := false
+	literal := false
+	for _, r := range path {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			literal = literal || brackets == 0
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			b.WriteRune(r)
+		case (r == '"' || r == '\'') && b.Len() == 0 && brackets == 0:
+			quote = r
+			literal = true
+		case r == '[':
+			brackets++
+			b.WriteRune(r)
+		case r == ']' && brackets > 0:
+			brackets--
+			b.WriteRune(r)
+		case r == PathSeparator && brackets == 0:
+			elements = append(elements, splitElement(b.String(), literal))
+			b.Reset()
+			literal = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if escaped {
+		b.WriteRune('\\')
+	}
+	elements = append(elements, splitElement(b.String(), literal))
+	if len(elements) == 1 && elements[0] == "" && path == "" {
+		return []string{}
+	}
+	return elements
+}
+
+// splitElement returns escaped and quoted elements that would otherwise be a pattern or a list index as literal keys.
+func splitElement(e string, literal bool) string {
+	if !literal {
+		return e
+	}
+	if _, err := strconv.Atoi(e); err == nil || isPattern(e) {
+		return LiteralKey(e)
+	}
+	return e
+}
+
+// JoinPath joins the path elements into a path string that SplitPath can split back.
+// Backslashes, separators, leading quotes and '[' outside of list filters are escaped with a backslash.
+// Literal keys are escaped so they are split back as literal keys, see LiteralKey.
+func JoinPath(p []string) string {
+	escaped := make([]string, len(p))
+	for i, e := range p {
+		e, literal := literalKey(e)
+		_, isFilter := parseFilter(e)
+		e = strings.ReplaceAll(e, "\\", "\\\\")
+		if literal || !isFilter {
+			e = strings.ReplaceAll(e, "[", "\\[")
+		}
+		if strings.HasPrefix(e, `"`) || strings.HasPrefix(e, "'") {
+			e = "\\" + e
+		}
+		e = strings.ReplaceAll(e, string(PathSeparator), "\\"+string(PathSeparator))
+		// Any escaped character makes the element a literal key
+		if literal && !strings.Contains(e, "\\") {
+			e = "\\" + e
+		}
+		escaped[i] = e
+	}
+	return strings.Join(escaped, string(PathSeparator))
+}
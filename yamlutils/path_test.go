@@ -0,0 +1,387 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const findInput = `containers:
+  - name: api
+    image: api:1.0
+  - name: sidecar
+    image: proxy:2.0
+    ports:
+      - name: http
+        port: 80
+metadata:
+  name: pod
+`
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     []string
+		expected []Match
+		err      error
+	}{
+		{"exact", []string{"metadata", "name"}, []Match{{[]string{"metadata", "name"}, "pod"}}, nil},
+		{"exact error", []string{"metadata", "x"}, []Match{}, ErrMapKeyNotFound},
+		{"wildcard list", []string{"containers", "*", "name"}, []Match{
+			{[]string{"containers", "0", "name"}, "api"},
+			{[]string{"containers", "1", "name"}, "sidecar"},
+		}, nil},
+		{"wildcard map", []string{"*", "name"}, []Match{
+			{[]string{"metadata", "name"}, "pod"},
+		}, nil},
+		{"wildcard skips missing", []string{"containers", "*", "ports", "*", "port"}, []Match{
+			{[]string{"containers", "1", "ports", "0", "port"}, 80},
+		}, nil},
+		{"wildcard no match", []string{"containers", "*", "x"}, []Match{}, ErrMapKeyNotFound},
+		{"recursive", []string{"**", "name"}, []Match{
+			{[]string{"containers", "0", "name"}, "api"},
+			{[]string{"containers", "1", "name"}, "sidecar"},
+			{[]string{"containers", "1", "ports", "0", "name"}, "http"},
+			{[]string{"metadata", "name"}, "pod"},
+		}, nil},
+		{"recursive under key", []string{"containers", "1", "**", "port"}, []Match{
+			{[]string{"containers", "1", "ports", "0", "port"}, 80},
+		}, nil},
+		{"filter", []string{"containers", "[name=sidecar]", "image"}, []Match{
+			{[]string{"containers", "1", "image"}, "proxy:2.0"},
+		}, nil},
+		{"filter multiple", []string{"containers", "[name!=x]", "name"}, []Match{
+			{[]string{"containers", "0", "name"}, "api"},
+			{[]string{"containers", "1", "name"}, "sidecar"},
+		}, nil},
+		{"filter no match", []string{"containers", "[name=x]"}, []Match{}, ErrNoMatch},
+		{"recursive filter", []string{"**", "[port<=80]", "name"}, []Match{
+			{[]string{"containers", "1", "ports", "0", "name"}, "http"},
+		}, nil},
+		{"slice", []string{"containers", "-1:", "name"}, []Match{
+			{[]string{"containers", "1", "name"}, "sidecar"},
+		}, nil},
+		{"negative index", []string{"containers", "-2", "name"}, []Match{
+			{[]string{"containers", "0", "name"}, "api"},
+		}, nil},
+		{"recursive last", []string{"metadata", "**"}, []Match{
+			{[]string{"metadata"}, map[interface{}]interface{}{"name": "pod"}},
+			{[]string{"metadata", "name"}, "pod"},
+		}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			yml, err := NewFromString(findInput)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output, err := yml.GetMatches(test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+			t.Log(buf.String())
+		})
+	}
+}
+
+func TestGetStringWildcard(t *testing.T) {
+	yml, err := NewFromString(findInput)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	output, err := yml.GetString(false, []string{"containers", "*", "name"})
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	expected := "- api\n- sidecar\n"
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+	output, err = yml.GetMatchesString([]string{"containers", "*", "image"})
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	expected = `- path: containers/0/image
+  value: api:1.0
+- path: containers/1/image
+  value: proxy:2.0
+`
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected filter
+		ok       bool
+	}{
+		{"name", filter{}, false},
+		{"[1]", filter{}, false},
+		{"[=a]", filter{}, false},
+		{"[name=api]", filter{"name", "=", "api"}, true},
+		{"[name = 'a b']", filter{"name", "=", "a b"}, true},
+		{"[name!=api]", filter{"name", "!=", "api"}, true},
+		{"[port<=80]", filter{"port", "<=", "80"}, true},
+		{"[port>=80]", filter{"port", ">=", "80"}, true},
+		{"[port<80]", filter{"port", "<", "80"}, true},
+		{"[url=http://a?b=c]", filter{"url", "=", "http://a?b=c"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			output, ok := parseFilter(test.input)
+			if ok != test.ok || !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v, %v\nGot:\n%#v, %v\n", test.expected, test.ok, output, ok)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	item := map[interface{}]interface{}{"name": "api", "port": 8080, "enabled": true, "empty": nil}
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{"[name=api]", true},
+		{"[name=web]", false},
+		{"[port=8080]", true},
+		{"[port>900]", true},
+		{"[port<900]", false},
+		{"[enabled=true]", true},
+		{"[empty=null]", true},
+		{"[missing=x]", false},
+	}
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			f, _ := parseFilter(test.filter)
+			if f.match(item) != test.expected {
+				t.Errorf("Expected: %v\n", test.expected)
+			}
+		})
+	}
+}
+
+func TestParseSlice(t *testing.T) {
+	tests := []struct {
+		input string
+		start int
+		end   int
+		ok    bool
+	}{
+		{"1", 0, 0, false},
+		{"a:b", 0, 0, false},
+		{":", 0, 5, true},
+		{"1:3", 1, 3, true},
+		{"-2:", 3, 5, true},
+		{":-1", 0, 4, true},
+		{"3:1", 3, 3, true},
+		{"-10:10", 0, 5, true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			start, end, ok := parseSlice(test.input, 5)
+			if start != test.start || end != test.end || ok != test.ok {
+				t.Errorf("Expected: %d, %d, %v\nGot: %d, %d, %v\n", test.start, test.end, test.ok, start, end, ok)
+			}
+		})
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a"}},
+		{"a/b/0", []string{"a", "b", "0"}},
+		{"a//b", []string{"a", "", "b"}},
+		{`labels/app.kubernetes.io\/name`, []string{"labels", "app.kubernetes.io/name"}},
+		{`labels/"app.kubernetes.io/name"/x`, []string{"labels", "app.kubernetes.io/name", "x"}},
+		{`labels/'a"b/c'`, []string{"labels", `a"b/c`}},
+		{`a\\/b`, []string{`a\`, "b"}},
+		{`it's/b`, []string{"it's", "b"}},
+		{`list/[url=http://example.com]/name`, []string{"list", "[url=http://example.com]", "name"}},
+		{`list/[url=http:\/\/example.com]`, []string{"list", "[url=http://example.com]"}},
+		{`a/\*/"**"`, []string{"a", LiteralKey("*"), LiteralKey("**")}},
+		{`\[x=y]/'1:3'/1\:3`, []string{LiteralKey("[x=y]"), LiteralKey("1:3"), LiteralKey("1:3")}},
+		{`list/"0"/\-1`, []string{"list", LiteralKey("0"), LiteralKey("-1")}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			output := SplitPath(test.input)
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"a", "b", "0"},
+		{"labels", "app.kubernetes.io/name"},
+		{`a\`, "b"},
+		{"list[0]", "[name=api]"},
+		{`"quoted"`, "'single'", `mid"dle`},
+		{"*", "**", "[x=y]", "1:3"},
+		{LiteralKey("*"), LiteralKey("**"), LiteralKey("[x=y]"), LiteralKey("1:3"), LiteralKey("0")},
+		{"a/[b]", "[b"},
+	}
+	for _, test := range tests {
+		t.Run(JoinPath(test), func(t *testing.T) {
+			output := SplitPath(JoinPath(test))
+			if !reflect.DeepEqual(output, test) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test, output)
+			}
+		})
+	}
+}
+
+func TestLiteralKeys(t *testing.T) {
+	input := `"*": star
+"**": stars
+"[x=y]": filter
+"1:3": slice
+a: b
+list:
+  - x: v
+  - x: w
+`
+	y, err := NewFromString(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	tests := []struct {
+		path     string
+		expected string
+		err      error
+	}{
+		{`\*`, "star", nil},
+		{`"**"`, "stars", nil},
+		{`\[x=y]`, "filter", nil},
+		{`'1:3'`, "slice", nil},
+		{`1\:3`, "slice", nil},
+		{`\a`, "b", nil},
+		{`list/"[x=y]"`, "", ErrNotAnIndex},
+		{`list/\*`, "", ErrNotAnIndex},
+		{`list/'1'`, "", ErrNotAnIndex},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			output, err := y.GetString(false, SplitPath(test.path))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			if err == nil && output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+	matches, err := FindAll(y.Tree, SplitPath("*"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	for _, m := range matches {
+		value, _, err := NavigateTree(false, y.Tree, SplitPath(JoinPath(m.Path)))
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s\n", JoinPath(m.Path), err)
+		}
+		if !reflect.DeepEqual(value, m.Value) {
+			t.Errorf("%s: Expected: %v, got: %v\n", JoinPath(m.Path), m.Value, value)
+		}
+	}
+	_, err = y.Set(SplitPath(`"1:3"`), "new", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	output, _ := y.GetString(false, []string{"1:3"})
+	if output != "new" {
+		t.Errorf("Expected: new, got: %s\n", output)
+	}
+}
+
+func TestNonStringKeys(t *testing.T) {
+	input := `ports:
+  8080: http
+  443: https
+flags:
+  true: enabled
+  ~: nothing
+  1.5: version
+labels:
+  app.kubernetes.io/name: api
+`
+	tests := []struct {
+		path     string
+		expected string
+		err      error
+	}{
+		{"ports/8080", "http", nil},
+		{"flags/true", "enabled", nil},
+		{"flags/null", "nothing", nil},
+		{"flags/~", "nothing", nil},
+		{"flags/1.5", "version", nil},
+		{`labels/app.kubernetes.io\/name`, "api", nil},
+		{`labels/"app.kubernetes.io/name"`, "api", nil},
+		{"ports/80", "443: https\n8080: http\n", ErrMapKeyNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			yml, err := NewFromString(input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output, err := yml.GetString(false, SplitPath(test.path))
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+			t.Log(buf.String())
+		})
+	}
+
+	yml, err := NewFromString(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	_, err = yml.Set([]string{"ports", "8080"}, "web", false)
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	_, err = yml.Delete([]string{"flags", "true"})
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	expected := map[interface{}]interface{}{
+		"ports":  map[interface{}]interface{}{8080: "web", 443: "https"},
+		"flags":  map[interface{}]interface{}{nil: "nothing", 1.5: "version"},
+		"labels": map[interface{}]interface{}{"app.kubernetes.io/name": "api"},
+	}
+	if !reflect.DeepEqual(yml.Tree, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v\n", expected, yml.Tree)
+	}
+}
@@ -0,0 +1,556 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// When a YML object has PreserveFormat set, the modified Tree is not marshalled from scratch.
+// Instead, the original source is parsed into a node representation and compared against the Tree.
+// Only the text of the elements that changed is replaced, the rest of the document, including comments, key order
+// and whitespace, is left byte-identical.
+// Elements that can't be edited in place (flow collections, anchors, block scalars, etc.) are rewritten as a whole
+// key/value pair or list item.
+
+// textEdit - Replaces the source bytes in the range [start, end) with text.
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+type preserver struct {
+	src   []byte
+	lines []int // byte offset of the start of each line.
+	edits []textEdit
+	// Anchors referenced by an alias, elements defining them can't be rewritten.
+	aliased map[string]bool
+}
+
+// marshalPreserved returns the source document with the minimal edits required for it to represent tree.
+func marshalPreserved(src []byte, tree interface{}) ([]byte, error) {
+	var doc yaml3.Node
+	err := yaml3.Unmarshal(src, &doc)
+	if err != nil || doc.Kind != yaml3.DocumentNode || len(doc.Content) == 0 {
+		Logger.Printf("marshalPreserved: unable to parse source, falling back to Marshal")
+		return yaml.Marshal(tree)
+	}
+	p := newPreserver(src)
+	aliasNames(doc.Content[0], p.aliased)
+	if !p.sync(doc.Content[0], tree) {
+		Logger.Printf("marshalPreserved: unable to preserve root, falling back to Marshal")
+		return yaml.Marshal(tree)
+	}
+	out, ok := p.apply()
+	if !ok {
+		Logger.Printf("marshalPreserved: overlapping edits, falling back to Marshal")
+		return yaml.Marshal(tree)
+	}
+	return out, nil
+}
+
+func newPreserver(src []byte) *preserver {
+	lines := []int{0}
+	for i, b := range src {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &preserver{src: src, lines: lines, aliased: map[string]bool{}}
+}
+
+// aliasNames adds the anchors referenced by the aliases of the node to names.
+func aliasNames(node *yaml3.Node, names map[string]bool) {
+	if node.Kind == yaml3.AliasNode {
+		names[node.Value] = true
+	}
+	for _, c := range node.Content {
+		aliasNames(c, names)
+	}
+}
+
+// definesAliased returns true when the node or its children define an anchor referenced by an alias.
+func (p *preserver) definesAliased(node *yaml3.Node) bool {
+	if node.Anchor != "" && p.aliased[node.Anchor] {
+		return true
+	}
+	for _, c := range node.Content {
+		if p.definesAliased(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *preserver) apply() ([]byte, bool) {
+	sort.SliceStable(p.edits, func(i, j int) bool { return p.edits[i].start < p.edits[j].start })
+	var out []byte
+	cursor := 0
+	for _, e := range p.edits {
+		if e.start < cursor {
+			return nil, false
+		}
+		out = append(out, p.src[cursor:e.start]...)
+		out = append(out, e.text...)
+		cursor = e.end
+	}
+	out = append(out, p.src[cursor:]...)
+	return out, true
+}
+
+// lineCount - Number of lines in the source, a trailing newline doesn't start a new line.
+func (p *preserver) lineCount() int {
+	if len(p.src) > 0 && p.src[len(p.src)-1] == '\n' {
+		return len(p.lines) - 1
+	}
+	return len(p.lines)
+}
+
+// lineStart returns the byte offset of the 1 based line.
+// Lines past the end of the source return the length of the source.
+func (p *preserver) lineStart(line int) int {
+	if line-1 >= len(p.lines) {
+		return len(p.src)
+	}
+	return p.lines[line-1]
+}
+
+func (p *preserver) lineText(line int) string {
+	start := p.lineStart(line)
+	end := p.lineStart(line + 1)
+	return strings.TrimRight(string(p.src[start:end]), "\r\n")
+}
+
+// offset returns the byte offset of the 1 based line and rune column.
+func (p *preserver) offset(line, column int) int {
+	start := p.lineStart(line)
+	text := p.lineText(line)
+	i := 0
+	for c := 1; c < column && i < len(text); c++ {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return start + i
+}
+
+func lineIndent(text string) int {
+	return len(text) - len(strings.TrimLeft(text, " "))
+}
+
+func isBlankOrComment(text string) bool {
+	t := strings.TrimSpace(text)
+	return t == "" || strings.HasPrefix(t, "#")
+}
+
+func isDocumentMarker(text string) bool {
+	return strings.HasPrefix(text, "---") || strings.HasPrefix(text, "...")
+}
+
+// headStart returns the first line of the comment block directly above line at the given indentation.
+func (p *preserver) headStart(line, indent int) int {
+	for line > 1 {
+		text := p.lineText(line - 1)
+		if strings.TrimSpace(text) == "" || !isBlankOrComment(text) || lineIndent(text) != indent {
+			break
+		}
+		line--
+	}
+	return line
+}
+
+// blockEnd returns the line after the last line that belongs to the block element starting at line with the given
+// indentation.
+// When seqAtIndent is true, list items at the same indentation are considered part of the element.
+// Trailing blank lines and comments at the element indentation or lower are not part of the element.
+func (p *preserver) blockEnd(line, indent int, seqAtIndent bool) int {
+	end := line + 1
+	for l := line + 1; l <= p.lineCount(); l++ {
+		text := p.lineText(l)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		i := lineIndent(text)
+		if isBlankOrComment(text) {
+			if i > indent {
+				end = l + 1
+			}
+			continue
+		}
+		if i == 0 && isDocumentMarker(text) {
+			break
+		}
+		if i > indent || (seqAtIndent && i == indent && (text[i:] == "-" || strings.HasPrefix(text[i:], "- "))) {
+			end = l + 1
+			continue
+		}
+		break
+	}
+	return end
+}
+
+// decodeNode returns the yaml.v2 representation of the node.
+// Aliases and merge keys are resolved, even when the anchor is defined outside of the node.
+func decodeNode(n *yaml3.Node) (interface{}, error) {
+	out, err := yaml3.Marshal(resolveNode(n))
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = yaml.Unmarshal(out, &v)
+	return v, err
+}
+
+// render marshals v and indents every line with indent spaces.
+func render(v interface{}, indent int) (string, error) {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.SplitAfter(string(out), "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			lines[i] = strings.Repeat(" ", indent) + l
+		}
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// insert adds text at the offset making sure it starts on a new line.
+func (p *preserver) insert(offset int, text string) {
+	if offset > 0 && offset == len(p.src) && p.src[offset-1] != '\n' {
+		text = "\n" + text
+	}
+	p.edits = append(p.edits, textEdit{offset, offset, text})
+}
+
+// sync adds the edits required for node to represent value.
+// Returns false when the node can't be edited in place and has to be rewritten by the parent.
+func (p *preserver) sync(node *yaml3.Node, value interface{}) bool {
+	if old, err := decodeNode(node); err == nil && reflect.DeepEqual(old, value) {
+		return true
+	}
+	if node.Anchor != "" || node.Kind == yaml3.AliasNode || node.Style&(yaml3.FlowStyle|yaml3.TaggedStyle) != 0 {
+		return false
+	}
+	n := len(p.edits)
+	ok := false
+	switch node.Kind {
+	case yaml3.MappingNode:
+		if m, isMap := value.(map[interface{}]interface{}); isMap {
+			ok = p.syncMapping(node, m)
+		}
+	case yaml3.SequenceNode:
+		if s, isSlice := value.([]interface{}); isSlice {
+			ok = p.syncSequence(node, s)
+		}
+	case yaml3.ScalarNode:
+		ok = p.syncScalar(node, value)
+	}
+	if !ok {
+		p.edits = p.edits[:n]
+	}
+	return ok
+}
+
+func (p *preserver) syncMapping(node *yaml3.Node, m map[interface{}]interface{}) bool {
+	if len(node.Content) == 0 || len(m) == 0 {
+		return false
+	}
+	indent := node.Content[0].Column - 1
+	firstInline := lineIndent(p.lineText(node.Content[0].Line)) != indent
+	seen := map[interface{}]bool{}
+	explicit := map[interface{}]bool{}
+	hasMerge := false
+	lastEnd := 0
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		if k.Kind != yaml3.ScalarNode {
+			return false
+		}
+		end := p.blockEnd(k.Line, indent, v.Kind == yaml3.SequenceNode)
+		lastEnd = end
+		// Merged keys are compared after the explicit ones
+		if isMergeKey(k) {
+			hasMerge = true
+			continue
+		}
+		key, err := decodeNode(k)
+		if err != nil {
+			return false
+		}
+		explicit[key] = true
+		nv, ok := m[key]
+		if !ok {
+			if i == 0 && firstInline || p.definesAliased(v) {
+				return false
+			}
+			Logger.Printf("syncMapping: delete key %v", key)
+			p.edits = append(p.edits, textEdit{p.lineStart(p.headStart(k.Line, indent)), p.lineStart(end), ""})
+			continue
+		}
+		seen[key] = true
+		if p.sync(v, nv) {
+			continue
+		}
+		// Anchors, aliases and tags on the key line are rewritten with the whole pair
+		if i == 0 && firstInline || p.definesAliased(v) {
+			return false
+		}
+		Logger.Printf("syncMapping: rewrite key %v", key)
+		text, err := render(map[interface{}]interface{}{key: nv}, indent)
+		if err != nil {
+			return false
+		}
+		p.edits = append(p.edits, textEdit{p.lineStart(k.Line), p.lineStart(end), text})
+	}
+	if hasMerge {
+		// Unchanged merged keys are kept, changed ones are added as explicit keys that override the merge
+		old, err := decodeNode(node)
+		merged, ok := old.(map[interface{}]interface{})
+		if err != nil || !ok {
+			return false
+		}
+		for k, v := range merged {
+			if explicit[k] {
+				continue
+			}
+			nv, ok := m[k]
+			if !ok {
+				Logger.Printf("syncMapping: unable to delete merged key %v", k)
+				return false
+			}
+			if reflect.DeepEqual(v, nv) {
+				seen[k] = true
+			}
+		}
+	}
+	added := map[interface{}]interface{}{}
+	for k, v := range m {
+		if !seen[k] {
+			added[k] = v
+		}
+	}
+	if len(added) > 0 {
+		Logger.Printf("syncMapping: add keys %v", added)
+		text, err := render(added, indent)
+		if err != nil {
+			return false
+		}
+		p.insert(p.lineStart(lastEnd), text)
+	}
+	return true
+}
+
+func (p *preserver) syncSequence(node *yaml3.Node, s []interface{}) bool {
+	if len(node.Content) == 0 || len(s) == 0 {
+		return false
+	}
+	indent := node.Column - 1
+	old := make([]interface{}, len(node.Content))
+	for i, item := range node.Content {
+		text := p.lineText(item.Line)
+		if lineIndent(text) != indent || text[indent] != '-' {
+			return false
+		}
+		v, err := decodeNode(item)
+		if err != nil {
+			return false
+		}
+		old[i] = v
+	}
+	itemStart := func(i int) int { return p.lineStart(p.headStart(node.Content[i].Line, indent)) }
+	itemEnd := func(i int) int { return p.lineStart(p.blockEnd(node.Content[i].Line, indent, false)) }
+
+	// Longest common subsequence of unchanged items.
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(s)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(s) - 1; j >= 0; j-- {
+			if reflect.DeepEqual(old[i], s[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var removed []int
+	var added []interface{}
+	// flush handles the gap of changed items before old item i.
+	flush := func(i int) bool {
+		for len(removed) > 0 && len(added) > 0 {
+			r := removed[0]
+			if !p.sync(node.Content[r], added[0]) {
+				Logger.Printf("syncSequence: rewrite item %d", r)
+				text, err := render([]interface{}{added[0]}, indent)
+				if err != nil {
+					return false
+				}
+				p.edits = append(p.edits, textEdit{p.lineStart(node.Content[r].Line), itemEnd(r), text})
+			}
+			removed, added = removed[1:], added[1:]
+		}
+		for _, r := range removed {
+			Logger.Printf("syncSequence: delete item %d", r)
+			p.edits = append(p.edits, textEdit{itemStart(r), itemEnd(r), ""})
+		}
+		if len(added) > 0 {
+			Logger.Printf("syncSequence: add items %v", added)
+			text, err := render(added, indent)
+			if err != nil {
+				return false
+			}
+			if i < len(old) {
+				p.insert(itemStart(i), text)
+			} else {
+				p.insert(itemEnd(len(old)-1), text)
+			}
+		}
+		removed, added = nil, nil
+		return true
+	}
+	i, j := 0, 0
+	for i < len(old) || j < len(s) {
+		switch {
+		case i < len(old) && j < len(s) && reflect.DeepEqual(old[i], s[j]):
+			if !flush(i) {
+				return false
+			}
+			i++
+			j++
+		case j < len(s) && (i == len(old) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, s[j])
+			j++
+		default:
+			removed = append(removed, i)
+			i++
+		}
+	}
+	return flush(len(old))
+}
+
+func (p *preserver) syncScalar(node *yaml3.Node, value interface{}) bool {
+	switch value.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return false
+	}
+	if node.Style&(yaml3.LiteralStyle|yaml3.FoldedStyle) != 0 || node.Value == "" || strings.Contains(node.Value, "\n") {
+		return false
+	}
+	start := p.offset(node.Line, node.Column)
+	line := p.lineText(node.Line)
+	rest := line[start-p.lineStart(node.Line):]
+	var end int
+	switch {
+	case node.Style&yaml3.DoubleQuotedStyle != 0:
+		end = quotedEnd(rest, '"')
+	case node.Style&yaml3.SingleQuotedStyle != 0:
+		end = quotedEnd(rest, '\'')
+	default:
+		if !strings.HasPrefix(rest, node.Value) {
+			return false
+		}
+		end = len(node.Value)
+	}
+	if end < 0 {
+		return false
+	}
+	text, err := renderScalar(value, node.Style)
+	if err != nil || strings.Contains(text, "\n") {
+		return false
+	}
+	Logger.Printf("syncScalar: replace '%s' with '%s'", rest[:end], text)
+	p.edits = append(p.edits, textEdit{start, start + end, text})
+	return true
+}
+
+// quotedEnd returns the length of the quoted scalar at the start of s or -1 if it doesn't end in s.
+func quotedEnd(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// renderScalar returns the inline representation of the scalar keeping the original quoting style for strings.
+func renderScalar(value interface{}, style yaml3.Style) (string, error) {
+	if s, ok := value.(string); ok {
+		switch {
+		case style&yaml3.DoubleQuotedStyle != 0:
+			out, err := json.Marshal(s)
+			return string(out), err
+		case style&yaml3.SingleQuotedStyle != 0:
+			if strings.Contains(s, "\n") {
+				return "", fmt.Errorf("multi-line string")
+			}
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+		}
+	}
+	out, err := yaml.Marshal(value)
+	return strings.TrimSuffix(string(out), "\n"), err
+}
+
+// preservedNodeAt returns the node designated by path.
+// Only exact map keys and list indexes are supported.
+func preservedNodeAt(src []byte, p []string) (*yaml3.Node, error) {
+	var doc yaml3.Node
+	err := yaml3.Unmarshal(src, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml3.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrExtraElementsInPath, pathString(p))
+	}
+	node := doc.Content[0]
+	for len(p) > 0 {
+		switch node.Kind {
+		case yaml3.MappingNode:
+			var next *yaml3.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if key, _ := literalKey(p[0]); node.Content[i].Value == key {
+					next = node.Content[i+1]
+				}
+			}
+			if next == nil {
+				return nil, fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(p[0]))
+			}
+			node = next
+		case yaml3.SequenceNode:
+			var index int
+			_, err := fmt.Sscanf(p[0], "%d", &index)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidIndex, pathElement(p[0]))
+			}
+			node = node.Content[index]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrExtraElementsInPath, pathString(p))
+		}
+		p = p[1:]
+	}
+	return node, nil
+}
@@ -0,0 +1,287 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const preserveInput = `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+# Replica count
+replicas: 3
+hosts:
+- one.example.com
+- two.example.com
+
+empty: {}
+`
+
+func TestPreserveFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(y *YML) (string, error)
+		expected string
+		err      error
+	}{
+		{"set scalar", func(y *YML) (string, error) { return y.Set([]string{"replicas"}, "5", false) }, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+# Replica count
+replicas: 5
+hosts:
+- one.example.com
+- two.example.com
+
+empty: {}
+`, nil},
+		{"set quoted scalars", func(y *YML) (string, error) {
+			_, err := y.Set([]string{"image", "name"}, "web", false)
+			if err != nil {
+				return "", err
+			}
+			return y.Set([]string{"image", "tag"}, "v1.1", false)
+		}, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "web"
+  tag: 'v1.1'   # aligned comment
+
+# Replica count
+replicas: 3
+hosts:
+- one.example.com
+- two.example.com
+
+empty: {}
+`, nil},
+		{"add map key", func(y *YML) (string, error) { return y.AddString([]string{"image"}, "pull: always") }, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+  pull: always
+
+# Replica count
+replicas: 3
+hosts:
+- one.example.com
+- two.example.com
+
+empty: {}
+`, nil},
+		{"add list item", func(y *YML) (string, error) { return y.AddString([]string{"hosts"}, "three.example.com") }, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+# Replica count
+replicas: 3
+hosts:
+- one.example.com
+- two.example.com
+- three.example.com
+
+empty: {}
+`, nil},
+		{"delete key with comment", func(y *YML) (string, error) { return y.Delete([]string{"replicas"}) }, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+hosts:
+- one.example.com
+- two.example.com
+
+empty: {}
+`, nil},
+		{"delete list item", func(y *YML) (string, error) { return y.Delete([]string{"hosts", "0"}) }, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+# Replica count
+replicas: 3
+hosts:
+- two.example.com
+
+empty: {}
+`, nil},
+		{"replace scalar with map", func(y *YML) (string, error) { return y.Set([]string{"zeta"}, "{a: 1}", false) }, `# Service configuration
+zeta:
+  a: 1
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+# Replica count
+replicas: 3
+hosts:
+- one.example.com
+- two.example.com
+
+empty: {}
+`, nil},
+		{"add to flow map", func(y *YML) (string, error) { return y.AddString([]string{"empty"}, "a: 1") }, `# Service configuration
+zeta: 1 # last alphabetically
+
+image:
+  name: "app"
+  tag: 'v1.0'   # aligned comment
+
+# Replica count
+replicas: 3
+hosts:
+- one.example.com
+- two.example.com
+
+empty:
+  a: 1
+`, nil},
+		{"error", func(y *YML) (string, error) { return y.Delete([]string{"x"}) }, preserveInput, ErrMapKeyNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			yml, err := NewFromString(preserveInput)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			yml.PreserveFormat = true
+			output, err := test.fn(yml)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+			t.Log(buf.String())
+		})
+	}
+}
+
+func TestPreserveFormatGetString(t *testing.T) {
+	yml, err := NewFromString(preserveInput)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	yml.PreserveFormat = true
+	output, err := yml.GetString(true, []string{"image"})
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	expected := `image:
+  name: "app"
+  tag: 'v1.0' # aligned comment
+`
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+}
+
+const preserveAnchorsInput = `# keep me
+defaults: &defaults
+  timeout: 5
+  retries: 3
+other: *defaults
+service:
+  <<: *defaults
+  name: api # name
+port: 80 # p
+`
+
+func TestPreserveFormatAnchors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(y *YML) (string, error)
+		expected string
+	}{
+		{"set unrelated key", func(y *YML) (string, error) { return y.Set([]string{"port"}, "81", false) }, `# keep me
+defaults: &defaults
+  timeout: 5
+  retries: 3
+other: *defaults
+service:
+  <<: *defaults
+  name: api # name
+port: 81 # p
+`},
+		{"set next to merge key", func(y *YML) (string, error) { return y.Set([]string{"service", "name"}, "web", false) }, `# keep me
+defaults: &defaults
+  timeout: 5
+  retries: 3
+other: *defaults
+service:
+  <<: *defaults
+  name: web # name
+port: 80 # p
+`},
+		{"set merged key", func(y *YML) (string, error) { return y.Set([]string{"service", "timeout"}, "10", false) }, `# keep me
+defaults: &defaults
+  timeout: 5
+  retries: 3
+other: *defaults
+service:
+  <<: *defaults
+  name: api # name
+  timeout: 10
+port: 80 # p
+`},
+		{"set alias", func(y *YML) (string, error) { return y.Set([]string{"other", "retries"}, "1", false) }, `# keep me
+defaults: &defaults
+  timeout: 5
+  retries: 3
+other:
+  retries: 1
+  timeout: 5
+service:
+  <<: *defaults
+  name: api # name
+port: 80 # p
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			yml, err := NewFromString(preserveAnchorsInput)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			yml.PreserveFormat = true
+			output, err := test.fn(yml)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReadProperties returns the Tree for a Java properties file.
+// Keys are split on '.' into nested maps and '[n]' suffixes select list items, for example:
+//
+//	db.host=localhost
+//	servers[0].name=api
+//
+// Values are always strings, later values of a repeated key replace earlier ones.
+// When a key has both a value and children, the children are kept as flat dotted keys in the closest map, for example:
+//
+//	log4j.appender.A1=org.apache.log4j.ConsoleAppender
+//	log4j.appender.A1.layout=org.apache.log4j.PatternLayout
+//
+// returns: {log4j: {appender: {A1: org.apache.log4j.ConsoleAppender, A1.layout: org.apache.log4j.PatternLayout}}}
+func ReadProperties(data []byte) (interface{}, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var tree interface{} = map[interface{}]interface{}{}
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for continuesLine(line) {
+			line = line[:len(line)-1]
+			if i+1 < len(lines) {
+				i++
+				line += strings.TrimLeft(lines[i], " \t\f")
+			}
+		}
+		key, value := splitProperty(line)
+		key, value = unescapeProperty(key), unescapeProperty(value)
+		path, err := propertyPath(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		tree = setProperty(tree, path, value)
+	}
+	return tree, nil
+}
+
+// setProperty sets the value at the path of map keys (string) and list indexes (int).
+// When the path conflicts with a previous key, the conflicting key is stored as a flat dotted key in the closest map:
+// a value below a previous value is stored with its remaining path and a previous map or list replaced by a value
+// has its leaves stored with their full paths.
+func setProperty(tree interface{}, path []interface{}, value string) interface{} {
+	// The root is always a map
+	parent := tree.(map[interface{}]interface{})
+	parentIndex := 0
+	var node interface{} = parent
+	for i, e := range path {
+		switch e := e.(type) {
+		case int:
+			l, ok := node.([]interface{})
+			if node != nil && !ok {
+				// Previous value where a list is expected
+				Logger.Printf("setProperty: flat key '%s'", joinPropertyPath(path[parentIndex:]))
+				parent[joinPropertyPath(path[parentIndex:])] = value
+				return tree
+			}
+			node = nil
+			if e < len(l) {
+				node = l[e]
+			}
+		default:
+			m, ok := node.(map[interface{}]interface{})
+			if node != nil && !ok {
+				// Previous value where a map is expected
+				Logger.Printf("setProperty: flat key '%s'", joinPropertyPath(path[parentIndex:]))
+				parent[joinPropertyPath(path[parentIndex:])] = value
+				return tree
+			}
+			if node == nil {
+				break
+			}
+			parent, parentIndex = m, i
+			_, node, _ = mapKey(m, KeyString(e))
+		}
+		if node == nil {
+			break
+		}
+	}
+	if isContainer(node) {
+		// Previous map or list where a value is expected
+		k, branch, _ := mapKey(parent, KeyString(path[parentIndex]))
+		_ = walkLeaves(branch, []interface{}{path[parentIndex]}, func(p []interface{}, v interface{}) error {
+			if !isEmptyContainer(v) {
+				Logger.Printf("setProperty: flat key '%s'", joinPropertyPath(p))
+				parent[joinPropertyPath(p)] = v
+			}
+			return nil
+		})
+		delete(parent, k)
+	}
+	tree, _ = setFlatValue(tree, path, value)
+	return tree
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// joinPropertyPath returns the unescaped property key for the path, map keys are joined with '.' and list indexes
+// use '[n]'.
+func joinPropertyPath(path []interface{}) string {
+	var b strings.Builder
+	for i, e := range path {
+		switch e := e.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", e)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(KeyString(e))
+		}
+	}
+	return b.String()
+}
+
+// continuesLine - A line ending with an odd number of backslashes continues on the next line.
+func continuesLine(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// splitProperty splits the logical line into its escaped key and value.
+// The key ends at the first unescaped '=', ':' or whitespace.
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, ":") {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return line[:i], rest
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 <= len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+var propertyIndexRegex = regexp.MustCompile(`\[(\d+)\]$`)
+
+// propertyPath splits the property key into map keys (string) and list indexes (int).
+func propertyPath(key string) ([]interface{}, error) {
+	path := []interface{}{}
+	for _, segment := range strings.Split(key, ".") {
+		indexes := []interface{}{}
+		for {
+			m := propertyIndexRegex.FindStringSubmatchIndex(segment)
+			if m == nil {
+				break
+			}
+			i, err := strconv.Atoi(segment[m[2]:m[3]])
+			if err != nil || i > maxListIndex {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidIndex, key, segment[m[0]:])
+			}
+			indexes = append([]interface{}{i}, indexes...)
+			segment = segment[:m[0]]
+		}
+		if segment == "" {
+			return nil, fmt.Errorf("%w: key '%s' has an empty element", ErrInvalidInput, key)
+		}
+		path = append(path, segment)
+		path = append(path, indexes...)
+	}
+	return path, nil
+}
+
+// scalarValue returns the string representation of a scalar, null is an empty string.
+func scalarValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return KeyString(v)
+}
+
+// WriteProperties returns the Java properties representation of the tree, the inverse of ReadProperties.
+// The tree must be a map, nested maps are joined with '.' and list items use '[n]'.
+// Null values are written as empty strings and empty maps and lists are omitted.
+func WriteProperties(tree interface{}) ([]byte, error) {
+	if tree == nil {
+		return []byte{}, nil
+	}
+	if _, ok := tree.(map[interface{}]interface{}); !ok {
+		return nil, fmt.Errorf("%w: properties document must be a map, found %s", ErrUnsupportedValue, kindOf(tree))
+	}
+	var b strings.Builder
+	err := walkLeaves(tree, []interface{}{}, func(path []interface{}, value interface{}) error {
+		if isEmptyContainer(value) {
+			return nil
+		}
+		for i, e := range path {
+			switch e := e.(type) {
+			case int:
+				fmt.Fprintf(&b, "[%d]", e)
+			default:
+				if i > 0 {
+					b.WriteByte('.')
+				}
+				b.WriteString(escapeProperty(KeyString(e), true))
+			}
+		}
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(scalarValue(value), false))
+		b.WriteByte('\n')
+		return nil
+	})
+	return []byte(b.String()), err
+}
+
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if key {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(c)
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+var dotenvNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ReadDotenv returns the Tree for a dotenv file, a map with one string value per 'KEY=value' line.
+// Lines can start with 'export '.
+// Single quoted values are literal, double quoted values support the \n, \r, \t, \", \\ and \$ escapes and both
+// can span several lines.
+// Unquoted values end at a ' #' comment and are trimmed.
+// Variables are not expanded, use Expand for that.
+func ReadDotenv(data []byte) (interface{}, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	tree := map[interface{}]interface{}{}
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.IndexByte(line, '=')
+		if eq == -1 {
+			return nil, fmt.Errorf("line %d: %w: expected KEY=value", n, ErrInvalidInput)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !dotenvNameRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d: %w: invalid variable name '%s'", n, ErrInvalidInput, key)
+		}
+		value := strings.TrimLeft(line[eq+1:], " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if c := strings.Index(value, " #"); c != -1 {
+				value = value[:c]
+			} else if c := strings.Index(value, "\t#"); c != -1 {
+				value = value[:c]
+			} else if strings.HasPrefix(value, "#") {
+				value = ""
+			}
+			tree[key] = strings.TrimSpace(value)
+			continue
+		}
+		quote := value[0]
+		value = value[1:]
+		end := closingQuote(value, quote)
+		for end == -1 {
+			if i+1 == len(lines) {
+				return nil, fmt.Errorf("line %d: %w: missing closing quote for '%s'", n, ErrInvalidInput, key)
+			}
+			i++
+			value += "\n" + lines[i]
+			end = closingQuote(value, quote)
+		}
+		if rest := strings.TrimSpace(value[end+1:]); rest != "" && rest[0] != '#' {
+			return nil, fmt.Errorf("line %d: %w: unexpected text after the value of '%s'", n, ErrInvalidInput, key)
+		}
+		value = value[:end]
+		if quote == '"' {
+			value = unescapeDotenv(value)
+		}
+		tree[key] = value
+	}
+	return tree, nil
+}
+
+// closingQuote returns the index of the quote closing s, skipping escaped double quotes, or -1.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && quote == '"' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+var dotenvPlainRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// WriteDotenv returns the dotenv representation of the tree, the inverse of ReadDotenv.
+// The tree must be a map, nested map keys and list indexes are joined with '_', for example 'db: {host: x}' is
+// written as 'db_host=x'.
+// Values are double quoted when they contain characters other than letters, digits and '_./:@%+,=-'.
+func WriteDotenv(tree interface{}) ([]byte, error) {
+	if tree == nil {
+		return []byte{}, nil
+	}
+	if _, ok := tree.(map[interface{}]interface{}); !ok {
+		return nil, fmt.Errorf("%w: dotenv document must be a map, found %s", ErrUnsupportedValue, kindOf(tree))
+	}
+	var b strings.Builder
+	err := walkLeaves(tree, []interface{}{}, func(path []interface{}, value interface{}) error {
+		if isEmptyContainer(value) {
+			return nil
+		}
+		elements := make([]string, len(path))
+		for i, e := range path {
+			elements[i] = KeyString(e)
+		}
+		name := strings.Join(elements, "_")
+		if !dotenvNameRegex.MatchString(name) {
+			return fmt.Errorf("%w: '%s' is not a valid variable name", ErrUnsupportedValue, name)
+		}
+		s := scalarValue(value)
+		if !dotenvPlainRegex.MatchString(s) {
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+			s = `"` + r.Replace(s) + `"`
+		}
+		fmt.Fprintf(&b, "%s=%s\n", name, s)
+		return nil
+	})
+	return []byte(b.String()), err
+}
@@ -0,0 +1,174 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReadProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+		err      error
+	}{
+		{"empty", "", map[interface{}]interface{}{}, nil},
+		{"separators", "a=1\nb: 2\nc 3\nd = 4\ne\n", map[interface{}]interface{}{
+			"a": "1", "b": "2", "c": "3", "d": "4", "e": "",
+		}, nil},
+		{"comments", "# comment\n! comment\n  \na=1\n", map[interface{}]interface{}{"a": "1"}, nil},
+		{"nested", "db.host=localhost\ndb.port=5432\n", map[interface{}]interface{}{
+			"db": map[interface{}]interface{}{"host": "localhost", "port": "5432"},
+		}, nil},
+		{"lists", "servers[1].name=b\nservers[0].name=a\nmatrix[0][1]=x\n", map[interface{}]interface{}{
+			"servers": []interface{}{
+				map[interface{}]interface{}{"name": "a"},
+				map[interface{}]interface{}{"name": "b"},
+			},
+			"matrix": []interface{}{[]interface{}{nil, "x"}},
+		}, nil},
+		{"continuation", "a=one \\\n    two\nb=c\\\\\n", map[interface{}]interface{}{
+			"a": "one two", "b": `c\`,
+		}, nil},
+		{"escapes", `key\ with\=equals=tab\there é\n`, map[interface{}]interface{}{
+			"key with=equals": "tab\there é\n",
+		}, nil},
+		{"repeated key", "a=1\na=2\n", map[interface{}]interface{}{"a": "2"}, nil},
+		{"value with separators", "url=http://host:80/a=b\n", map[interface{}]interface{}{"url": "http://host:80/a=b"}, nil},
+		{"crlf", "a=1\r\nb=2\r\n", map[interface{}]interface{}{"a": "1", "b": "2"}, nil},
+		{"log4j", "log4j.appender.A1=org.apache.log4j.ConsoleAppender\nlog4j.appender.A1.layout=org.apache.log4j.PatternLayout\n",
+			map[interface{}]interface{}{
+				"log4j": map[interface{}]interface{}{
+					"appender": map[interface{}]interface{}{
+						"A1":        "org.apache.log4j.ConsoleAppender",
+						"A1.layout": "org.apache.log4j.PatternLayout",
+					},
+				},
+			}, nil},
+		{"value after children", "a.b.c=1\na.b.d[0]=2\na.b=3\n", map[interface{}]interface{}{
+			"a": map[interface{}]interface{}{"b": "3", "b.c": "1", "b.d[0]": "2"},
+		}, nil},
+		{"list conflict", "a=1\na[0]=2\nb[0]=x\nb[0].c=y\n", map[interface{}]interface{}{
+			"a": "1", "a[0]": "2", "b": []interface{}{"x"}, "b[0].c": "y",
+		}, nil},
+		{"empty element", "a..b=1\n", nil, ErrInvalidInput},
+		{"large index", "a[1000000]=1\n", nil, ErrInvalidIndex},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := ReadProperties([]byte(test.input))
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if err == nil && !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected: %#v, got: %#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestWriteProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"escapes", `{"a b": " x:y", "c=d": "line\nnext", "e": "back\\slash"}`, `a\ b=\ x:y
+c\=d=line\nnext
+e=back\\slash
+`},
+		{"empty containers", `{"a": {}, "b": [], "c": 1}`, "c=1\n"},
+		{"nested lists", `{"m": [[1, 2], [3]]}`, "m[0][0]=1\nm[0][1]=2\nm[1][0]=3\n"},
+		{"flat dotted keys", `{"A1": "console", "A1.layout": "pattern"}`, "A1=console\nA1.layout=pattern\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y, _ := NewFromString(test.input)
+			output, err := WriteProperties(y.Tree)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if string(output) != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestReadDotenv(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+		err      error
+	}{
+		{"empty", "", map[interface{}]interface{}{}, nil},
+		{"plain", "A=1\nexport B=two\n  C = three  \nD=\n", map[interface{}]interface{}{
+			"A": "1", "B": "two", "C": "three", "D": "",
+		}, nil},
+		{"comments", "# comment\nA=1 # comment\nB=a#b\nC=#x\n", map[interface{}]interface{}{
+			"A": "1", "B": "a#b", "C": "",
+		}, nil},
+		{"single quotes", `A='lit $HOME \n' # comment`, map[interface{}]interface{}{"A": `lit $HOME \n`}, nil},
+		{"double quotes", `A="x\ny \"q\" \$HOME \\ ${B}"`, map[interface{}]interface{}{
+			"A": "x\ny \"q\" $HOME \\ ${B}",
+		}, nil},
+		{"multiline", "A=\"one\ntwo\"\nB='three\nfour'\n", map[interface{}]interface{}{
+			"A": "one\ntwo", "B": "three\nfour",
+		}, nil},
+		{"dotted names", "app.name=x\nAPP-ENV=y\n", map[interface{}]interface{}{"app.name": "x", "APP-ENV": "y"}, nil},
+		{"missing equals", "A\n", nil, ErrInvalidInput},
+		{"invalid name", "1A=x\n", nil, ErrInvalidInput},
+		{"unterminated quote", "A=\"x\nB=y\n", nil, ErrInvalidInput},
+		{"text after quote", `A="x" y`, nil, ErrInvalidInput},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := ReadDotenv([]byte(test.input))
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if err == nil && !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected: %#v, got: %#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestWriteDotenv(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"plain", `{"A": "x", "B": 1, "C": "http://host:80/path", "D": null}`, "A=x\nB=1\nC=http://host:80/path\nD=\n"},
+		{"quoted", `{"A": "a b", "B": "x\"y$z\\", "C": "one\ntwo"}`, `A="a b"
+B="x\"y\$z\\"
+C="one\ntwo"
+`},
+		{"nested", `{"db": {"host": "x", "ports": [1, 2]}}`, "db_host=x\ndb_ports_0=1\ndb_ports_1=2\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			y, _ := NewFromString(test.input)
+			output, err := WriteDotenv(y.Tree)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if string(output) != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
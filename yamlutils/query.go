@@ -0,0 +1,156 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// QueryFormat - Format used by QueryString to print the results of several queries.
+type QueryFormat string
+
+const (
+	// QueryLines - One value per line.
+	QueryLines QueryFormat = "lines"
+	// QueryTSV - All the values in a single tab separated line.
+	QueryTSV QueryFormat = "tsv"
+	// QueryJSON - JSON object with the query as the key and the result as the value.
+	QueryJSON QueryFormat = "json"
+)
+
+// ParseQueryFormat returns the QueryFormat for the given name.
+// Valid names are: lines, tsv and json.
+func ParseQueryFormat(name string) (QueryFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "lines":
+		return QueryLines, nil
+	case "tsv":
+		return QueryTSV, nil
+	case "json":
+		return QueryJSON, nil
+	}
+	return QueryLines, fmt.Errorf("%w: %s", ErrInvalidFormat, name)
+}
+
+// QueryResult - Result of a single query.
+type QueryResult struct {
+	// Query - Path as given to Query.
+	Query string
+	// Value - Element found, a list with every match when the path has wildcards.
+	Value interface{}
+	// Err - Error found while running the query, Value is nil.
+	Err error
+}
+
+// Query runs each path independently and returns one result per path in the same order.
+// Paths use the SplitPath syntax and return the same element as NavigateTree, paths with wildcards return a list with
+// every match.
+// When include is true, values designated by a map key are returned in a map with the key, see NavigateTree.
+// A failing query doesn't stop the rest, its error is returned in its result.
+func (y *YML) Query(include bool, queries []string) []QueryResult {
+	results := make([]QueryResult, len(queries))
+	for i, q := range queries {
+		results[i].Query = q
+		value, _, err := NavigateTree(include, y.Tree, SplitPath(q))
+		if err != nil {
+			results[i].Err = fmt.Errorf("yaml path '%s': %w", q, err)
+			continue
+		}
+		results[i].Value = value
+	}
+	Logger.Printf("query: %d queries", len(queries))
+	return results
+}
+
+// QueryString returns the results in the given format followed by a newline.
+// In the lines and tsv formats, with YAML output, scalars are printed as is, null as an empty value and maps and lists
+// in flow style, with JSON output every value is printed as JSON.
+// Failed queries print an empty value, and backslashes, tabs and newlines in values are escaped as '\\', '\t' and
+// '\n' so every value takes a single field.
+// In the json format failed queries are null, repeated queries are only included once and the object is indented
+// with the FormatJSONPretty output.
+func QueryString(results []QueryResult, format QueryFormat, output OutputFormat) (string, error) {
+	switch format {
+	case QueryLines, QueryTSV:
+		values := make([]string, len(results))
+		for i, r := range results {
+			if r.Err == nil {
+				v, err := queryValue(r.Value, output)
+				if err != nil {
+					return "", fmt.Errorf("query '%s': %w", r.Query, err)
+				}
+				values[i] = escapeQueryValue(v)
+			}
+		}
+		sep := "\n"
+		if format == QueryTSV {
+			sep = "\t"
+		}
+		return strings.Join(values, sep) + "\n", nil
+	case QueryJSON:
+		var b strings.Builder
+		seen := map[string]bool{}
+		b.WriteString("{")
+		for _, r := range results {
+			if seen[r.Query] {
+				continue
+			}
+			if len(seen) > 0 {
+				b.WriteString(",")
+			}
+			seen[r.Query] = true
+			b.WriteString(diffValue(r.Query))
+			b.WriteString(":")
+			if r.Err != nil {
+				b.WriteString("null")
+			} else {
+				b.WriteString(diffValue(r.Value))
+			}
+		}
+		b.WriteString("}")
+		if output != FormatJSONPretty {
+			return b.String() + "\n", nil
+		}
+		var buf bytes.Buffer
+		err := json.Indent(&buf, []byte(b.String()), "", "  ")
+		return buf.String() + "\n", err
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+}
+
+func queryValue(v interface{}, output OutputFormat) (string, error) {
+	if output == FormatJSON || output == FormatJSONPretty {
+		y := &YML{Format: output}
+		out, err := y.marshal(v)
+		return strings.TrimSuffix(string(out), "\n"), err
+	}
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		var node yaml3.Node
+		err := node.Encode(v)
+		if err != nil {
+			return "", err
+		}
+		node.Style = yaml3.FlowStyle
+		out, err := yaml3.Marshal(&node)
+		return strings.TrimSuffix(string(out), "\n"), err
+	case nil:
+		return "", nil
+	}
+	return KeyString(v), nil
+}
+
+func escapeQueryValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
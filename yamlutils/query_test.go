@@ -0,0 +1,127 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var queryDoc = `
+name: api
+port: 8080
+user: null
+description: "multi\tline\ntext"
+db:
+  host: localhost
+servers:
+  - name: a
+  - name: b
+`
+
+func TestQuery(t *testing.T) {
+	y, _ := NewFromString(queryDoc)
+	results := y.Query(false, []string{"name", "missing", "db", "servers/*/name", "servers/[name=c]", "port",
+		"servers/0:1", "servers/[name!=c]"})
+	expected := []QueryResult{
+		{Query: "name", Value: "api"},
+		{Query: "missing"},
+		{Query: "db", Value: map[interface{}]interface{}{"host": "localhost"}},
+		{Query: "servers/*/name", Value: []interface{}{"a", "b"}},
+		{Query: "servers/[name=c]"},
+		{Query: "port", Value: 8080},
+		{Query: "servers/0:1", Value: []interface{}{map[interface{}]interface{}{"name": "a"}}},
+		{Query: "servers/[name!=c]"},
+	}
+	errs := []error{nil, ErrMapKeyNotFound, nil, nil, ErrNoMatch, nil, nil, ErrMultipleMatches}
+	if len(results) != len(expected) {
+		t.Fatalf("Unexpected results: %v\n", results)
+	}
+	for i, r := range results {
+		if !errors.Is(r.Err, errs[i]) {
+			t.Errorf("Query %s: unexpected error: %s\n", r.Query, r.Err)
+		}
+		if r.Query != expected[i].Query || !reflect.DeepEqual(r.Value, expected[i].Value) {
+			t.Errorf("Expected: %#v, got: %#v\n", expected[i], r)
+		}
+	}
+}
+
+func TestQueryInclude(t *testing.T) {
+	y, _ := NewFromString(queryDoc)
+	results := y.Query(true, []string{"db/host", "servers/0"})
+	expected := []interface{}{
+		map[interface{}]interface{}{"host": "localhost"},
+		map[interface{}]interface{}{"name": "a"},
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("Query %s: unexpected error: %s\n", r.Query, r.Err)
+		}
+		if !reflect.DeepEqual(r.Value, expected[i]) {
+			t.Errorf("Expected: %#v, got: %#v\n", expected[i], r.Value)
+		}
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	y, _ := NewFromString(queryDoc)
+	results := y.Query(false, []string{"name", "missing", "port", "user", "description", "servers/*/name", "name", "db"})
+	tests := []struct {
+		name     string
+		format   QueryFormat
+		output   OutputFormat
+		expected string
+		err      error
+	}{
+		{"lines", QueryLines, FormatYAML, "api\n\n8080\n\nmulti\\tline\\ntext\n[a, b]\napi\n{host: localhost}\n", nil},
+		{"tsv", QueryTSV, FormatYAML, "api\t\t8080\t\tmulti\\tline\\ntext\t[a, b]\tapi\t{host: localhost}\n", nil},
+		{"lines json", QueryLines, FormatJSON, "\"api\"\n\n8080\nnull\n\"multi\\\\tline\\\\ntext\"\n[\"a\",\"b\"]\n\"api\"\n{\"host\":\"localhost\"}\n", nil},
+		{"lines json-pretty", QueryLines, FormatJSONPretty, "\"api\"\n\n8080\nnull\n\"multi\\\\tline\\\\ntext\"\n[\\n  \"a\",\\n  \"b\"\\n]\n\"api\"\n{\\n  \"host\": \"localhost\"\\n}\n", nil},
+		{"json", QueryJSON, FormatYAML, `{"name":"api","missing":null,"port":8080,"user":null,"description":"multi\tline\ntext","servers/*/name":["a","b"],"db":{"host":"localhost"}}` + "\n", nil},
+		{"json pretty", QueryJSON, FormatJSONPretty, "{\n  \"name\": \"api\",\n  \"missing\": null,\n  \"port\": 8080,\n  \"user\": null,\n  \"description\": \"multi\\tline\\ntext\",\n  \"servers/*/name\": [\n    \"a\",\n    \"b\"\n  ],\n  \"db\": {\n    \"host\": \"localhost\"\n  }\n}\n", nil},
+		{"xml", QueryFormat("xml"), FormatYAML, "", ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := QueryString(results, test.format, test.output)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%q\ngot:\n%q\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestParseQueryFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected QueryFormat
+		err      error
+	}{
+		{"", QueryLines, nil},
+		{"TSV", QueryTSV, nil},
+		{"json", QueryJSON, nil},
+		{"csv", QueryLines, ErrInvalidFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := ParseQueryFormat(test.name)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected: %s, got: %s\n", test.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,646 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrInvalidSchema - The JSON Schema can't be used for validation.
+var ErrInvalidSchema = fmt.Errorf("invalid schema")
+
+// maxRefDepth - Maximum number of nested $ref resolutions for a single instance, protects against $ref cycles.
+const maxRefDepth = 100
+
+// Schema - JSON Schema used to validate YML trees.
+//
+// Supports the draft 7 validation keywords as well as $defs, dependentRequired and dependentSchemas from later
+// drafts.
+// $ref can point to the same document or to other local files relative to the schema file.
+// The format keyword is treated as an annotation and not validated.
+type Schema struct {
+	doc *schemaDoc
+	// documents loaded through $ref, by absolute filename
+	docs     map[string]*schemaDoc
+	patterns map[string]*regexp.Regexp
+}
+
+type schemaDoc struct {
+	filename string
+	root     interface{}
+}
+
+// Violation - Validation error found at Path.
+type Violation struct {
+	Path    []string
+	Message string
+}
+
+// String returns the violation in the form: path: message.
+func (v Violation) String() string {
+	path := JoinPath(v.Path)
+	if path == "" {
+		path = "."
+	}
+	return fmt.Sprintf("%s: %s", path, v.Message)
+}
+
+// NewSchemaFromFile returns a pointer to a Schema object from a JSON or YAML file.
+func NewSchemaFromFile(filename string) (*Schema, error) {
+	doc, err := readSchemaFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := NewSchema(nil)
+	s.doc = doc
+	s.docs[doc.filename] = doc
+	return s, nil
+}
+
+// NewSchema returns a pointer to a Schema object from a YAML or JSON tree.
+// Relative $ref files are resolved from the current directory.
+func NewSchema(tree interface{}) *Schema {
+	return &Schema{
+		doc:      &schemaDoc{root: JSONCompatible(tree)},
+		docs:     map[string]*schemaDoc{},
+		patterns: map[string]*regexp.Regexp{},
+	}
+}
+
+func readSchemaFile(filename string) (*schemaDoc, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	err = yaml.Unmarshal(data, &tree)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidSchema, filename, err)
+	}
+	return &schemaDoc{filename: filename, root: JSONCompatible(tree)}, nil
+}
+
+// Validate returns the violations of the YML tree against the schema, see Schema.Validate.
+func (y *YML) Validate(s *Schema) ([]Violation, error) {
+	return s.Validate(y.Tree)
+}
+
+// Validate returns all the violations of the tree against the schema.
+// An empty list means the tree is valid.
+// An error is returned when the schema itself can't be used, for example with an unresolvable $ref or an invalid
+// pattern.
+func (s *Schema) Validate(tree interface{}) ([]Violation, error) {
+	violations, err := s.validate(s.doc, s.doc.root, JSONCompatible(tree), []string{}, 0)
+	if violations == nil {
+		violations = []Violation{}
+	}
+	return violations, err
+}
+
+func (s *Schema) validate(doc *schemaDoc, schema, instance interface{}, path []string, depth int) ([]Violation, error) {
+	switch sc := schema.(type) {
+	case bool:
+		if !sc {
+			return []Violation{{path, "no value is allowed"}}, nil
+		}
+		return nil, nil
+	case map[string]interface{}:
+		v := &schemaValidator{s: s, doc: doc, schema: sc, instance: instance, path: path, depth: depth}
+		err := v.run()
+		return v.violations, err
+	}
+	return nil, fmt.Errorf("%w: schema must be an object or a boolean", ErrInvalidSchema)
+}
+
+// schemaValidator - Validates an instance against a single schema object.
+type schemaValidator struct {
+	s          *Schema
+	doc        *schemaDoc
+	schema     map[string]interface{}
+	instance   interface{}
+	path       []string
+	depth      int
+	violations []Violation
+}
+
+func (v *schemaValidator) addf(format string, a ...interface{}) {
+	v.violations = append(v.violations, Violation{v.path, fmt.Sprintf(format, a...)})
+}
+
+// sub validates value against a subschema and adds its violations.
+func (v *schemaValidator) sub(schema, value interface{}, path []string) error {
+	violations, err := v.s.validate(v.doc, schema, value, path, v.depth)
+	v.violations = append(v.violations, violations...)
+	return err
+}
+
+// valid returns true when the instance is valid against the subschema.
+func (v *schemaValidator) valid(schema interface{}) (bool, error) {
+	violations, err := v.s.validate(v.doc, schema, v.instance, v.path, v.depth)
+	return len(violations) == 0, err
+}
+
+func (v *schemaValidator) run() error {
+	if ref, ok := v.schema["$ref"].(string); ok {
+		if v.depth >= maxRefDepth {
+			return fmt.Errorf("%w: too many nested $ref: %s", ErrInvalidSchema, ref)
+		}
+		doc, schema, err := v.s.resolve(v.doc, ref)
+		if err != nil {
+			return err
+		}
+		violations, err := v.s.validate(doc, schema, v.instance, v.path, v.depth+1)
+		v.violations = append(v.violations, violations...)
+		if err != nil {
+			return err
+		}
+	}
+	checks := []func() error{v.checkType, v.checkEnum, v.checkNumber, v.checkString, v.checkArray, v.checkObject,
+		v.checkCombinators}
+	for _, check := range checks {
+		err := check()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema referenced by ref and the document that contains it.
+func (s *Schema) resolve(doc *schemaDoc, ref string) (*schemaDoc, interface{}, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if parts[0] != "" {
+		if strings.Contains(parts[0], "://") {
+			return nil, nil, fmt.Errorf("%w: only local $ref are supported: %s", ErrInvalidSchema, ref)
+		}
+		filename := parts[0]
+		if !filepath.IsAbs(filename) && doc.filename != "" {
+			filename = filepath.Join(filepath.Dir(doc.filename), filename)
+		}
+		filename, err := filepath.Abs(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		if d, ok := s.docs[filename]; ok {
+			doc = d
+		} else {
+			doc, err = readSchemaFile(filename)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: $ref %s: %s", ErrInvalidSchema, ref, err)
+			}
+			s.docs[filename] = doc
+		}
+	}
+	pointer := ""
+	if len(parts) > 1 {
+		pointer = parts[1]
+	}
+	p, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: $ref %s: %s", ErrInvalidSchema, ref, err)
+	}
+	schema, err := pointerGet(doc.root, p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: $ref %s: %s", ErrInvalidSchema, ref, err)
+	}
+	return doc, schema, nil
+}
+
+func (s *Schema) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pattern %s: %s", ErrInvalidSchema, pattern, err)
+	}
+	s.patterns[pattern] = re
+	return re, nil
+}
+
+// instanceType returns the JSON Schema type of the value.
+func instanceType(value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case float64:
+		if t == math.Trunc(t) && !math.IsInf(t, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	if _, ok := toFloat(value); ok {
+		return "integer"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (v *schemaValidator) checkType() error {
+	var types []interface{}
+	switch t := v.schema["type"].(type) {
+	case nil:
+		return nil
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	default:
+		return fmt.Errorf("%w: type must be a string or a list", ErrInvalidSchema)
+	}
+	actual := instanceType(v.instance)
+	names := make([]string, len(types))
+	for i, t := range types {
+		name, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("%w: type must be a string or a list", ErrInvalidSchema)
+		}
+		if name == actual || (name == "number" && actual == "integer") {
+			return nil
+		}
+		names[i] = name
+	}
+	v.addf("expected type %s, got %s", strings.Join(names, " or "), actual)
+	return nil
+}
+
+func (v *schemaValidator) checkEnum() error {
+	if enum, ok := v.schema["enum"]; ok {
+		list, ok := enum.([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: enum must be a list", ErrInvalidSchema)
+		}
+		found := false
+		for _, e := range list {
+			if valuesEqual(e, v.instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.addf("value %s must be one of %s", diffValue(v.instance), diffValue(list))
+		}
+	}
+	if c, ok := v.schema["const"]; ok && !valuesEqual(c, v.instance) {
+		v.addf("value %s must be %s", diffValue(v.instance), diffValue(c))
+	}
+	return nil
+}
+
+// number returns the numeric value of the keyword.
+func (v *schemaValidator) number(keyword string) (float64, bool, error) {
+	value, ok := v.schema[keyword]
+	if !ok {
+		return 0, false, nil
+	}
+	f, ok := toFloat(value)
+	if !ok {
+		return 0, false, fmt.Errorf("%w: %s must be a number", ErrInvalidSchema, keyword)
+	}
+	return f, true, nil
+}
+
+func (v *schemaValidator) checkNumber() error {
+	n, ok := toFloat(v.instance)
+	if !ok {
+		return nil
+	}
+	checks := []struct {
+		keyword string
+		fail    func(n, limit float64) bool
+		message string
+	}{
+		{"minimum", func(n, l float64) bool { return n < l }, "value %v must be >= %v"},
+		{"maximum", func(n, l float64) bool { return n > l }, "value %v must be <= %v"},
+		{"exclusiveMinimum", func(n, l float64) bool { return n <= l }, "value %v must be > %v"},
+		{"exclusiveMaximum", func(n, l float64) bool { return n >= l }, "value %v must be < %v"},
+		{"multipleOf", func(n, l float64) bool { q := n / l; return math.Abs(q-math.Round(q)) > 1e-9 }, "value %v must be a multiple of %v"},
+	}
+	for _, c := range checks {
+		limit, ok, err := v.number(c.keyword)
+		if err != nil {
+			return err
+		}
+		if ok && c.fail(n, limit) {
+			v.addf(c.message, n, limit)
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkString() error {
+	s, ok := v.instance.(string)
+	if !ok {
+		return nil
+	}
+	length := utf8.RuneCountInString(s)
+	if min, ok, err := v.number("minLength"); err != nil {
+		return err
+	} else if ok && float64(length) < min {
+		v.addf("length %d must be >= %v", length, min)
+	}
+	if max, ok, err := v.number("maxLength"); err != nil {
+		return err
+	} else if ok && float64(length) > max {
+		v.addf("length %d must be <= %v", length, max)
+	}
+	if pattern, ok := v.schema["pattern"].(string); ok {
+		re, err := v.s.pattern(pattern)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(s) {
+			v.addf("value %s doesn't match pattern '%s'", diffValue(s), pattern)
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkArray() error {
+	list, ok := v.instance.([]interface{})
+	if !ok {
+		return nil
+	}
+	if min, ok, err := v.number("minItems"); err != nil {
+		return err
+	} else if ok && float64(len(list)) < min {
+		v.addf("list has %d items, must have at least %v", len(list), min)
+	}
+	if max, ok, err := v.number("maxItems"); err != nil {
+		return err
+	} else if ok && float64(len(list)) > max {
+		v.addf("list has %d items, must have at most %v", len(list), max)
+	}
+	if unique, _ := v.schema["uniqueItems"].(bool); unique {
+	duplicates:
+		for i := range list {
+			for j := i + 1; j < len(list); j++ {
+				if valuesEqual(list[i], list[j]) {
+					v.addf("items %d and %d must be unique", i, j)
+					break duplicates
+				}
+			}
+		}
+	}
+
+	// Items validated by a positional schema, the rest are validated by additionalItems
+	positional := 0
+	items := v.schema["items"]
+	if prefix, ok := v.schema["prefixItems"].([]interface{}); ok {
+		positional = len(prefix)
+		items = prefix
+	}
+	switch t := items.(type) {
+	case nil:
+	case []interface{}:
+		positional = len(t)
+		for i := 0; i < len(t) && i < len(list); i++ {
+			err := v.sub(t[i], list[i], appendPath(v.path, fmt.Sprint(i)))
+			if err != nil {
+				return err
+			}
+		}
+		additional := v.schema["additionalItems"]
+		if _, ok := v.schema["prefixItems"]; ok {
+			additional = v.schema["items"]
+		}
+		if additional != nil {
+			for i := positional; i < len(list); i++ {
+				err := v.sub(additional, list[i], appendPath(v.path, fmt.Sprint(i)))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		for i := range list {
+			err := v.sub(t, list[i], appendPath(v.path, fmt.Sprint(i)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if contains, ok := v.schema["contains"]; ok {
+		matches := 0
+		for _, item := range list {
+			violations, err := v.s.validate(v.doc, contains, item, v.path, v.depth)
+			if err != nil {
+				return err
+			}
+			if len(violations) == 0 {
+				matches++
+			}
+		}
+		min, hasMin, err := v.number("minContains")
+		if err != nil {
+			return err
+		}
+		if !hasMin {
+			min = 1
+		}
+		max, hasMax, err := v.number("maxContains")
+		if err != nil {
+			return err
+		}
+		if float64(matches) < min || (hasMax && float64(matches) > max) {
+			v.addf("list has %d items matching contains", matches)
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkObject() error {
+	m, ok := v.instance.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if min, ok, err := v.number("minProperties"); err != nil {
+		return err
+	} else if ok && float64(len(m)) < min {
+		v.addf("map has %d keys, must have at least %v", len(m), min)
+	}
+	if max, ok, err := v.number("maxProperties"); err != nil {
+		return err
+	} else if ok && float64(len(m)) > max {
+		v.addf("map has %d keys, must have at most %v", len(m), max)
+	}
+	if required, ok := v.schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if _, ok := m[fmt.Sprint(r)]; !ok {
+				v.addf("missing required key '%v'", r)
+			}
+		}
+	}
+
+	properties, _ := v.schema["properties"].(map[string]interface{})
+	patternProperties, _ := v.schema["patternProperties"].(map[string]interface{})
+	patterns := make([]string, 0, len(patternProperties))
+	for p := range patternProperties {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	for _, k := range keys {
+		path := appendPath(v.path, k)
+		if names, ok := v.schema["propertyNames"]; ok {
+			violations, err := v.s.validate(v.doc, names, k, path, v.depth)
+			if err != nil {
+				return err
+			}
+			for _, violation := range violations {
+				v.violations = append(v.violations, Violation{path, "key name: " + violation.Message})
+			}
+		}
+		matched := false
+		if schema, ok := properties[k]; ok {
+			matched = true
+			err := v.sub(schema, m[k], path)
+			if err != nil {
+				return err
+			}
+		}
+		for _, p := range patterns {
+			re, err := v.s.pattern(p)
+			if err != nil {
+				return err
+			}
+			if re.MatchString(k) {
+				matched = true
+				err := v.sub(patternProperties[p], m[k], path)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if additional, ok := v.schema["additionalProperties"]; ok && !matched {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.violations = append(v.violations, Violation{path, fmt.Sprintf("key '%s' is not allowed", k)})
+				continue
+			}
+			err := v.sub(additional, m[k], path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// draft 7 dependencies is split into dependentRequired and dependentSchemas in later drafts
+	dependencies := map[string]interface{}{}
+	for _, keyword := range []string{"dependencies", "dependentRequired", "dependentSchemas"} {
+		if d, ok := v.schema[keyword].(map[string]interface{}); ok {
+			for k, dep := range d {
+				dependencies[k] = dep
+			}
+		}
+	}
+	for _, k := range keys {
+		dep, ok := dependencies[k]
+		if !ok {
+			continue
+		}
+		if list, ok := dep.([]interface{}); ok {
+			for _, r := range list {
+				if _, ok := m[fmt.Sprint(r)]; !ok {
+					v.addf("key '%v' is required when '%s' is present", r, k)
+				}
+			}
+			continue
+		}
+		err := v.sub(dep, v.instance, v.path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkCombinators() error {
+	if allOf, ok := v.schema["allOf"].([]interface{}); ok {
+		for _, schema := range allOf {
+			err := v.sub(schema, v.instance, v.path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		list, ok := v.schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matches := 0
+		for _, schema := range list {
+			valid, err := v.valid(schema)
+			if err != nil {
+				return err
+			}
+			if valid {
+				matches++
+			}
+		}
+		if keyword == "anyOf" && matches == 0 {
+			v.addf("value must match at least one of the anyOf schemas")
+		}
+		if keyword == "oneOf" && matches != 1 {
+			v.addf("value must match exactly one of the oneOf schemas, matched %d", matches)
+		}
+	}
+	if not, ok := v.schema["not"]; ok {
+		valid, err := v.valid(not)
+		if err != nil {
+			return err
+		}
+		if valid {
+			v.addf("value must not match the not schema")
+		}
+	}
+	if cond, ok := v.schema["if"]; ok {
+		valid, err := v.valid(cond)
+		if err != nil {
+			return err
+		}
+		branch := "else"
+		if valid {
+			branch = "then"
+		}
+		if schema, ok := v.schema[branch]; ok {
+			err := v.sub(schema, v.instance, v.path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
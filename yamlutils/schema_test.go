@@ -0,0 +1,166 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		doc      string
+		expected []string
+	}{
+		{"valid", `{"type": "object", "properties": {"a": {"type": "integer"}}}`, "a: 1\n", []string{}},
+		{"type", `{"properties": {"a": {"type": "integer"}}}`, "a: x\n", []string{"a: expected type integer, got string"}},
+		{"type list", `{"type": ["string", "null"]}`, "1\n", []string{".: expected type string or null, got integer"}},
+		{"number accepts integer", `{"type": "number"}`, "1\n", []string{}},
+		{"integer accepts integral float", `{"type": "integer"}`, "1.0\n", []string{}},
+		{"integer rejects float", `{"type": "integer"}`, "1.5\n", []string{".: expected type integer, got number"}},
+		{"required", `{"required": ["a", "b"]}`, "a: 1\n", []string{".: missing required key 'b'"}},
+		{"enum", `{"enum": ["a", 1]}`, "b\n", []string{`.: value "b" must be one of ["a",1]`}},
+		{"enum number", `{"enum": [1.0]}`, "1\n", []string{}},
+		{"const", `{"const": {"a": 1}}`, "a: 2\n", []string{`.: value {"a":2} must be {"a":1}`}},
+		{"minimum", `{"minimum": 1, "maximum": 3}`, "[0, 2, 4]\n", []string{}},
+		{"minimum items", `{"items": {"minimum": 1, "maximum": 3}}`, "[0, 2, 4]\n",
+			[]string{"0: value 0 must be >= 1", "2: value 4 must be <= 3"}},
+		{"exclusive", `{"items": {"exclusiveMinimum": 1, "exclusiveMaximum": 3}}`, "[1, 2, 3]\n",
+			[]string{"0: value 1 must be > 1", "2: value 3 must be < 3"}},
+		{"multipleOf", `{"items": {"multipleOf": 0.1}}`, "[0.3, 0.35]\n", []string{"1: value 0.35 must be a multiple of 0.1"}},
+		{"string", `{"minLength": 2, "maxLength": 3, "pattern": "^a"}`, "bcde\n",
+			[]string{".: length 4 must be <= 3", `.: value "bcde" doesn't match pattern '^a'`}},
+		{"unicode length", `{"maxLength": 2}`, "ñú\n", []string{}},
+		{"items", `{"minItems": 2, "maxItems": 3, "uniqueItems": true}`, "[1]\n",
+			[]string{".: list has 1 items, must have at least 2"}},
+		{"unique", `{"uniqueItems": true}`, "[1, {a: 1}, {a: 1}]\n", []string{".: items 1 and 2 must be unique"}},
+		{"tuple", `{"items": [{"type": "string"}, {"type": "integer"}], "additionalItems": false}`, "[a, b, c]\n",
+			[]string{"1: expected type integer, got string", "2: no value is allowed"}},
+		{"prefixItems", `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}}`, "[a, b]\n",
+			[]string{"1: expected type integer, got string"}},
+		{"contains", `{"contains": {"type": "integer"}}`, "[a, b]\n", []string{".: list has 0 items matching contains"}},
+		{"maxContains", `{"contains": {"type": "integer"}, "maxContains": 1}`, "[1, 2]\n",
+			[]string{".: list has 2 items matching contains"}},
+		{"additionalProperties", `{"properties": {"a": {}}, "patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`,
+			"a: 1\nx-b: 2\nc: 3\n", []string{"c: key 'c' is not allowed", "x-b: expected type string, got integer"}},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "string"}}`, "a: 1\n",
+			[]string{"a: expected type string, got integer"}},
+		{"propertyNames", `{"propertyNames": {"pattern": "^[a-z]+$"}}`, "aB: 1\n",
+			[]string{`aB: key name: value "aB" doesn't match pattern '^[a-z]+$'`}},
+		{"properties count", `{"minProperties": 2, "maxProperties": 1}`, "a: 1\n",
+			[]string{".: map has 1 keys, must have at least 2"}},
+		{"dependencies", `{"dependencies": {"a": ["b"], "c": {"required": ["d"]}}}`, "a: 1\nc: 1\n",
+			[]string{".: key 'b' is required when 'a' is present", ".: missing required key 'd'"}},
+		{"dependentRequired", `{"dependentRequired": {"a": ["b"]}}`, "a: 1\n",
+			[]string{".: key 'b' is required when 'a' is present"}},
+		{"allOf", `{"allOf": [{"type": "string"}, {"minLength": 3}]}`, "ab\n", []string{".: length 2 must be >= 3"}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`, "1\n",
+			[]string{".: value must match at least one of the anyOf schemas"}},
+		{"oneOf", `{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, "1\n",
+			[]string{".: value must match exactly one of the oneOf schemas, matched 2"}},
+		{"not", `{"not": {"type": "string"}}`, "a\n", []string{".: value must not match the not schema"}},
+		{"if then else", `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["x"]}, "else": {"required": ["y"]}}`,
+			"kind: b\n", []string{".: missing required key 'y'"}},
+		{"ref definitions", `{"definitions": {"port": {"type": "integer", "maximum": 65535}}, "properties": {"ports": {"items": {"$ref": "#/definitions/port"}}}}`,
+			"ports: [80, 70000]\n", []string{"ports/1: value 70000 must be <= 65535"}},
+		{"ref $defs recursive", `{"$defs": {"node": {"properties": {"children": {"items": {"$ref": "#/$defs/node"}}, "name": {"type": "string"}}}}, "$ref": "#/$defs/node"}`,
+			"name: a\nchildren:\n- name: b\n  children:\n  - name: 1\n", []string{"children/0/children/0/name: expected type string, got integer"}},
+		{"escaped path", `{"additionalProperties": {"type": "string"}}`, "a/b: 1\n", []string{`a\/b: expected type string, got integer`}},
+		{"false schema", `false`, "a\n", []string{".: no value is allowed"}},
+		{"true schema", `true`, "a\n", []string{}},
+		{"null", `{"properties": {"a": {"type": "null"}}}`, "a: ~\n", []string{}},
+		{"non string keys", `{"properties": {"1": {"type": "string"}}}`, "1: 2\n", []string{"1: expected type string, got integer"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schemaYML, err := NewFromString(test.schema)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			y, err := NewFromString(test.doc)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			violations, err := y.Validate(NewSchema(schemaYML.Tree))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			output := []string{}
+			for _, v := range violations {
+				output = append(output, v.String())
+			}
+			if !reflect.DeepEqual(output, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"missing ref", `{"$ref": "#/definitions/missing"}`},
+		{"remote ref", `{"$ref": "https://example.com/schema.json"}`},
+		{"ref cycle", `{"$ref": "#"}`},
+		{"invalid pattern", `{"pattern": "("}`},
+		{"invalid type", `{"type": 1}`},
+		{"invalid schema", `[]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schemaYML, err := NewFromString(test.schema)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			_, err = NewSchema(schemaYML.Tree).Validate("a")
+			if !errors.Is(err, ErrInvalidSchema) {
+				t.Errorf("Unexpected error: %v\n", err)
+			}
+		})
+	}
+}
+
+func TestNewSchemaFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "schema.json"),
+		[]byte(`{"$schema": "http://json-schema.org/draft-07/schema#", "properties": {"image": {"$ref": "defs.yml#/image"}}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "defs.yml"),
+		[]byte("image:\n  required: [tag]\n  properties:\n    tag: {$ref: '#/tag'}\ntag:\n  type: string\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSchemaFromFile(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	y, _ := NewFromString("image:\n  tag: 1\n")
+	violations, err := y.Validate(s)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := []Violation{{[]string{"image", "tag"}, "expected type string, got integer"}}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("Expected:\n%#v\nGot:\n%#v\n", expected, violations)
+	}
+}
@@ -0,0 +1,206 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// YMLStream - Holds all the documents of a multi-document YAML stream.
+type YMLStream struct {
+	Docs []*YML
+	// Text before each document in the source, the document separator and any blank lines and comments before the
+	// first one, so the stream can be written back with the original separators.
+	separators []string
+}
+
+// NewStreamFromFile returns a pointer to a YMLStream object from a file.
+func NewStreamFromFile(filename string) (*YMLStream, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newStream(data)
+}
+
+// NewStreamFromReader returns a pointer to a YMLStream object from an io.Reader.
+func NewStreamFromReader(reader io.Reader) (*YMLStream, error) {
+	buf := new(bytes.Buffer)
+	_, err := buf.ReadFrom(reader)
+	if err != nil {
+		return nil, err
+	}
+	return newStream(buf.Bytes())
+}
+
+// NewStreamFromString returns a pointer to a YMLStream object from a string.
+func NewStreamFromString(str string) (*YMLStream, error) {
+	return newStream([]byte(str))
+}
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$\n?`)
+
+func newStream(data []byte) (*YMLStream, error) {
+	stream := &YMLStream{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var tree interface{}
+		err := decoder.Decode(&tree)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Empty and null documents are kept so indexes match the document positions
+		stream.Docs = append(stream.Docs, &YML{Tree: tree})
+	}
+	// The source of each document is only kept when it can be reliably matched, it is only used to preserve format.
+	src := string(data)
+	parts, separators := []string{}, []string{""}
+	start := 0
+	for _, loc := range documentSeparator.FindAllStringIndex(src, -1) {
+		parts = append(parts, src[start:loc[0]])
+		separators = append(separators, src[loc[0]:loc[1]])
+		start = loc[1]
+	}
+	parts = append(parts, src[start:])
+	// Blank content before the first separator is not a document
+	if len(parts) > 1 && isBlankSource(parts[0]) {
+		separators[1] = parts[0] + separators[1]
+		parts, separators = parts[1:], separators[1:]
+	}
+	if len(parts) == len(stream.Docs) {
+		for i, doc := range stream.Docs {
+			doc.source = []byte(parts[i])
+		}
+		stream.separators = separators
+	}
+	Logger.Printf("newStream: documents: %d", len(stream.Docs))
+	return stream, nil
+}
+
+// Doc returns the document at the given index.
+func (s *YMLStream) Doc(index int) (*YML, error) {
+	if index < 0 || len(s.Docs) <= index {
+		return nil, fmt.Errorf("document %w: %d", ErrInvalidIndex, index)
+	}
+	return s.Docs[index], nil
+}
+
+// Find returns the documents for which the predicate is true.
+func (s *YMLStream) Find(predicate func(*YML) bool) []*YML {
+	docs := []*YML{}
+	for _, doc := range s.Docs {
+		if predicate(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// KeyEquals returns a predicate that is true when the element designated by path is a scalar equal to value.
+// For example: KeyEquals([]string{"kind"}, "Deployment")
+func KeyEquals(keys []string, value string) func(*YML) bool {
+	return func(y *YML) bool {
+		target, _, err := NavigateTree(false, y.Tree, keys)
+		if err != nil {
+			return false
+		}
+		switch o := target.(type) {
+		case string, int, uint, float32, float64, bool:
+			return fmt.Sprintf("%v", o) == value
+		}
+		return false
+	}
+}
+
+// Document returns all the documents of the stream joined with their original separators, see join.
+// Empty documents are returned as empty documents, see emptyDocument.
+func (s *YMLStream) Document() (string, error) {
+	docs := make([]string, len(s.Docs))
+	for i, doc := range s.Docs {
+		if d, ok := doc.emptyDocument(); ok {
+			docs[i] = d
+			continue
+		}
+		var err error
+		docs[i], err = doc.Document()
+		if err != nil {
+			return "", fmt.Errorf("document %d: %w", i, err)
+		}
+	}
+	return s.join(docs), nil
+}
+
+// join joins the documents with the separators of the source, the first document only has a separator when the
+// source had one.
+// Streams that were not read from a source, or whose number of documents changed, are joined with JoinDocuments.
+func (s *YMLStream) join(docs []string) string {
+	if len(s.separators) != len(docs) {
+		return JoinDocuments(docs)
+	}
+	var b strings.Builder
+	for i, doc := range docs {
+		sep := s.separators[i]
+		if sep != "" && doc != "" && !strings.HasSuffix(sep, "\n") {
+			// Separator at the end of the source without a newline
+			sep += "\n"
+		}
+		b.WriteString(sep)
+		b.WriteString(doc)
+		if doc != "" && !strings.HasSuffix(doc, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// emptyDocument returns the source of a document with a null Tree that was empty or only had comments in the
+// stream, ok is false for any other document.
+func (y *YML) emptyDocument() (string, bool) {
+	if y.Tree != nil || !isBlankSource(string(y.source)) {
+		return "", false
+	}
+	if y.PreserveFormat {
+		return string(y.source), true
+	}
+	return "", true
+}
+
+// isBlankSource - The source only has blank lines and comments.
+func isBlankSource(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// JoinDocuments joins the given documents into a valid multi-document stream.
+func JoinDocuments(docs []string) string {
+	var b strings.Builder
+	for _, doc := range docs {
+		b.WriteString("---\n")
+		b.WriteString(doc)
+		if doc != "" && !strings.HasSuffix(doc, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const streamInput = `---
+kind: Service
+metadata:
+  name: api
+---
+# Deployment
+kind: Deployment
+metadata:
+  name: api
+---
+kind: Deployment
+metadata:
+  name: web
+`
+
+func TestYMLStream(t *testing.T) {
+	s := ""
+	buf := bytes.NewBufferString(s)
+	Logger.SetOutput(buf)
+	stream, err := NewStreamFromString(streamInput)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if len(stream.Docs) != 3 {
+		t.Fatalf("Expected 3 documents, got %d\n", len(stream.Docs))
+	}
+	doc, err := stream.Doc(1)
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	output, err := doc.GetString(false, []string{"kind"})
+	if err != nil || output != "Deployment" {
+		t.Errorf("Unexpected output: %s, %v\n", output, err)
+	}
+	_, err = stream.Doc(3)
+	if !errors.Is(err, ErrInvalidIndex) {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+
+	docs := stream.Find(KeyEquals([]string{"kind"}, "Deployment"))
+	var names []string
+	for _, d := range docs {
+		name, _ := d.GetString(false, []string{"metadata", "name"})
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{"api", "web"}) {
+		t.Errorf("Unexpected names: %v\n", names)
+	}
+
+	doc.PreserveFormat = true
+	output, err = doc.Set([]string{"metadata", "name"}, "api-v2", false)
+	if err != nil {
+		t.Errorf("Unexpected error: %s\n", err)
+	}
+	expected := "# Deployment\nkind: Deployment\nmetadata:\n  name: api-v2\n"
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+	t.Log(buf.String())
+}
+
+func TestJoinDocuments(t *testing.T) {
+	output := JoinDocuments([]string{"a: 1\n", "hello"})
+	expected := "---\na: 1\n---\nhello\n"
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, output)
+	}
+}
+
+func TestYMLStreamEmptyDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		count    int
+		preserve bool
+		expected string
+	}{
+		{"empty document", "a: 1\n---\n---\nb: 2\n", 3, false, "a: 1\n---\n---\nb: 2\n"},
+		{"leading separator", "---\na: 1\n", 1, false, "---\na: 1\n"},
+		{"trailing separator", "a: 1\n---\n", 2, false, "a: 1\n---\n"},
+		{"null document", "a: 1\n---\nnull\n", 2, false, "a: 1\n---\nnull\n"},
+		{"comment only", "a: 1\n---\n# comment\n---\nb: 2\n", 3, true, "a: 1\n---\n# comment\n---\nb: 2\n"},
+		{"single document", "a: 1\n", 1, true, "a: 1\n"},
+		{"separator comments", "# header\n--- # first\na: 1\n---   # second\nb: 2 # b\n", 2, true,
+			"# header\n--- # first\na: 1\n---   # second\nb: 2 # b\n"},
+		{"separator without newline", "a: 1\n---", 2, false, "a: 1\n---"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream, err := NewStreamFromString(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if len(stream.Docs) != test.count {
+				t.Fatalf("Expected %d documents, got %d\n", test.count, len(stream.Docs))
+			}
+			for _, doc := range stream.Docs {
+				doc.PreserveFormat = test.preserve
+			}
+			output, err := stream.Document()
+			if err != nil {
+				t.Fatalf("Unexpected error: %s\n", err)
+			}
+			if output != test.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s\n", test.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/benedict-pureweb/go-utils/fileutils"
+)
+
+// WriteFile writes the full document to filename in the format given by its name, see FileFormatFromName and
+// WriteFileWithFormat.
+func (y *YML) WriteFile(filename, backupSuffix string) error {
+	return y.WriteFileWithFormat(filename, FileFormatFromName(filename), backupSuffix)
+}
+
+// WriteFileWithFormat writes the full document to filename in the given file format, see Encode.
+// The YML output Format is ignored so the file keeps its format.
+// The file is replaced atomically through a temporary file in the same directory and keeps its original file mode.
+// When backupSuffix is not empty, the original file is first copied to filename + backupSuffix.
+func (y *YML) WriteFileWithFormat(filename string, format FileFormat, backupSuffix string) error {
+	data, err := y.Encode(format)
+	if err != nil {
+		return fmt.Errorf("failed to Marshal output: %w", err)
+	}
+	err = writeFile(filename, data, backupSuffix)
+	if err != nil {
+		return err
+	}
+	if format == FileYAML {
+		y.source = data
+	}
+	return nil
+}
+
+// WriteFile writes all the documents of the stream to filename in the format given by its name, see
+// WriteFileWithFormat.
+func (s *YMLStream) WriteFile(filename, backupSuffix string) error {
+	return s.WriteFileWithFormat(filename, FileFormatFromName(filename), backupSuffix)
+}
+
+// WriteFileWithFormat writes all the documents of the stream to filename, see YML.WriteFileWithFormat.
+// YAML files are written as a multi-document stream, JSON files require a stream with a single document.
+func (s *YMLStream) WriteFileWithFormat(filename string, format FileFormat, backupSuffix string) error {
+	if format != FileYAML {
+		if len(s.Docs) != 1 {
+			return fmt.Errorf("%w: %s files hold a single document, found %d", ErrUnsupportedValue, format, len(s.Docs))
+		}
+		return s.Docs[0].WriteFileWithFormat(filename, format, backupSuffix)
+	}
+	docs := make([]string, len(s.Docs))
+	for i, doc := range s.Docs {
+		if d, ok := doc.emptyDocument(); ok {
+			docs[i] = d
+			continue
+		}
+		data, err := doc.Encode(FileYAML)
+		if err != nil {
+			return fmt.Errorf("document %d: failed to Marshal output: %w", i, err)
+		}
+		docs[i] = string(data)
+	}
+	return writeFile(filename, []byte(s.join(docs)), backupSuffix)
+}
+
+func writeFile(filename string, data []byte, backupSuffix string) error {
+	Logger.Printf("writeFile: %s", filename)
+	if backupSuffix != "" {
+		info, err := os.Stat(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			backup := filename + backupSuffix
+			Logger.Printf("writeFile: backup %s", backup)
+			err = fileutils.CopyFile(filename, backup)
+			if err != nil {
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+			err = os.Chmod(backup, info.Mode().Perm())
+			if err != nil {
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+		}
+	}
+	return fileutils.WriteFileAtomic(filename, data, 0644)
+}
@@ -0,0 +1,187 @@
+// This file is part of go-utils.
+//
+// Copyright (C) 2019  David Gamba Rios
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package yamlutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.yml")
+	original := "# comment\na: 1   # one\nb: 2\n"
+	err = ioutil.WriteFile(filename, []byte(original), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	y, err := NewFromFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	y.PreserveFormat = true
+	_, err = y.Set([]string{"b"}, "3", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	err = y.WriteFile(filename, ".bak")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+
+	expected := "# comment\na: 1   # one\nb: 3\n"
+	data, _ := ioutil.ReadFile(filename)
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(data))
+	}
+	info, _ := os.Stat(filename)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected file mode: %s\n", info.Mode())
+	}
+	backup, _ := ioutil.ReadFile(filename + ".bak")
+	if string(backup) != original {
+		t.Errorf("Expected backup:\n%s\nGot:\n%s\n", original, string(backup))
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("Unexpected files left in dir: %d\n", len(files))
+	}
+
+	// Without backup
+	_, err = y.Set([]string{"a"}, "0", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	err = y.WriteFile(filename, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected = "# comment\na: 0   # one\nb: 3\n"
+	data, _ = ioutil.ReadFile(filename)
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(data))
+	}
+	backup, _ = ioutil.ReadFile(filename + ".bak")
+	if string(backup) != original {
+		t.Errorf("Backup was modified:\n%s\n", string(backup))
+	}
+}
+
+func TestStreamWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.yml")
+	err = ioutil.WriteFile(filename, []byte("---\na: 1\n---\nb: 2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStreamFromFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	_, err = s.Docs[1].Set([]string{"b"}, "3", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	err = s.WriteFile(filename, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := "---\na: 1\n---\nb: 3\n"
+	data, _ := ioutil.ReadFile(filename)
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(data))
+	}
+
+	// Empty documents keep their position
+	err = ioutil.WriteFile(filename, []byte("a: 1\n---\n---\nb: 2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewStreamFromFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	_, err = s.Docs[2].Set([]string{"b"}, "3", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	err = s.WriteFile(filename, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected = "a: 1\n---\n---\nb: 3\n"
+	data, _ = ioutil.ReadFile(filename)
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(data))
+	}
+}
+
+func TestWriteFileJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yamlutils")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.json")
+	err = ioutil.WriteFile(filename, []byte(`{"a": 1, "b": {"c": "x"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := NewFromFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	// The output format doesn't change the format of the file
+	y.PreserveFormat = true
+	_, err = y.Set([]string{"b", "c"}, "z", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	err = y.WriteFile(filename, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected := "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": \"z\"\n  }\n}\n"
+	data, _ := ioutil.ReadFile(filename)
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(data))
+	}
+	r, err := NewFromFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	if !reflect.DeepEqual(r.Tree, y.Tree) {
+		t.Errorf("Expected: %#v, got: %#v\n", y.Tree, r.Tree)
+	}
+
+	// A YAML file stays YAML with JSON output
+	filename = filepath.Join(dir, "test.yml")
+	y.Format = FormatJSON
+	err = y.WriteFile(filename, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s\n", err)
+	}
+	expected = "a: 1\nb:\n  c: z\n"
+	data, _ = ioutil.ReadFile(filename)
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(data))
+	}
+}
@@ -19,11 +19,11 @@ import (
 	"io/ioutil"
 	"log"
 
-	// "reflect"
-	"strconv"
+	"reflect"
 	"strings"
 
 	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
 )
 
 // Logger - Custom lib logger
@@ -32,6 +32,15 @@ var Logger = log.New(ioutil.Discard, "yamlutils ", log.LstdFlags)
 // YML object
 type YML struct {
 	Tree interface{}
+
+	// PreserveFormat - When true, the documents returned after modifying the Tree keep the comments, key order and
+	// formatting of the original source, only the modified elements are rewritten.
+	PreserveFormat bool
+
+	// Format - Output format used when returning results, defaults to YAML.
+	Format OutputFormat
+
+	source []byte
 }
 
 // NewFromFile returns a pointer to a YML object from a file.
@@ -45,7 +54,7 @@ func NewFromFile(filename string) (*YML, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &YML{Tree: tree}, nil
+	return &YML{Tree: tree, source: data}, nil
 }
 
 // NewFromReader returns a pointer to a YML object from an io.Reader.
@@ -60,7 +69,7 @@ func NewFromReader(reader io.Reader) (*YML, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &YML{Tree: tree}, nil
+	return &YML{Tree: tree, source: buf.Bytes()}, nil
 }
 
 // NewFromString - returns a pointer to a YML object from a string.
@@ -70,7 +79,7 @@ func NewFromString(str string) (*YML, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &YML{Tree: tree}, nil
+	return &YML{Tree: tree, source: []byte(str)}, nil
 }
 
 // GetString returns a string designated by path.
@@ -78,18 +87,18 @@ func NewFromString(str string) (*YML, error) {
 // Array indexes are given as a number.
 // For example: "level1/level2/3/level4"
 func (y *YML) GetString(include bool, keys []string) (string, error) {
-	path := strings.Join(keys, ",")
+	path := strings.Join(pathElements(keys), ",")
 	target, _, errPath := NavigateTree(include, y.Tree, keys)
 	// Check if response is a single element
 	switch o := target.(type) {
 	case string, int, uint, float32, float64, bool:
 		if errPath != nil {
-			return fmt.Sprintf("%v", o), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
+			return y.scalarString(o), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
 		}
-		return fmt.Sprintf("%v", o), nil
+		return y.scalarString(o), nil
 	}
 	// Marshal complex response
-	out, err := yaml.Marshal(target)
+	out, err := y.marshalTarget(include, keys, target)
 	if errPath != nil {
 		return string(out), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
 	}
@@ -101,18 +110,18 @@ func (y *YML) GetString(include bool, keys []string) (string, error) {
 }
 
 func (y *YML) AddString(keys []string, input string) (string, error) {
-	path := strings.Join(keys, ",")
+	path := strings.Join(pathElements(keys), ",")
 	errPath := AddChildToTree(&y.Tree, &y.Tree, keys, input)
 	// Check if response is a single element
 	switch o := y.Tree.(type) {
 	case string, int, uint, float32, float64, bool:
 		if errPath != nil {
-			return fmt.Sprintf("%v", o), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
+			return y.scalarString(o), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
 		}
-		return fmt.Sprintf("%v", o), nil
+		return y.scalarString(o), nil
 	}
 	// Marshal complex response
-	out, err := yaml.Marshal(y.Tree)
+	out, err := y.marshalTree()
 	if errPath != nil {
 		if errors.Is(errPath, ErrInvalidChildTypeKeyValue) {
 			return string(out), errPath
@@ -129,6 +138,61 @@ func (y *YML) AddString(keys []string, input string) (string, error) {
 	return string(out), nil
 }
 
+// Document returns the full document represented by the Tree.
+// When PreserveFormat is set, the original source is only modified where the Tree changed.
+func (y *YML) Document() (string, error) {
+	out, err := y.marshalTree()
+	if err != nil {
+		return string(out), fmt.Errorf("failed to Marshal output: %w", err)
+	}
+	return string(out), nil
+}
+
+// marshalTree returns the document representation of the Tree.
+func (y *YML) marshalTree() ([]byte, error) {
+	if y.PreserveFormat && y.source != nil && !y.jsonOutput() {
+		return marshalPreserved(y.source, y.Tree)
+	}
+	return y.marshal(y.Tree)
+}
+
+// marshalTarget returns the representation of the target element found at path.
+// When preserving the format, the element keeps the comments and key order of the original source.
+func (y *YML) marshalTarget(include bool, keys []string, target interface{}) ([]byte, error) {
+	if !y.PreserveFormat || y.source == nil || y.jsonOutput() {
+		return y.marshal(target)
+	}
+	node, err := preservedNodeAt(y.source, keys)
+	if err != nil {
+		return y.marshal(target)
+	}
+	// Only use the source when the Tree hasn't been modified since it was read
+	if old, err := decodeNode(node); err != nil || !reflect.DeepEqual(old, target) {
+		if !include || len(keys) == 0 {
+			return y.marshal(target)
+		}
+		m, ok := target.(map[interface{}]interface{})
+		if !ok || len(m) != 1 {
+			return y.marshal(target)
+		}
+		_, value, ok := mapKey(m, keys[len(keys)-1])
+		if err != nil || !ok || !reflect.DeepEqual(old, value) {
+			return y.marshal(target)
+		}
+		key := &yaml3.Node{Kind: yaml3.ScalarNode, Value: keys[len(keys)-1]}
+		node = &yaml3.Node{Kind: yaml3.MappingNode, Content: []*yaml3.Node{key, node}}
+	}
+	buf := new(bytes.Buffer)
+	enc := yaml3.NewEncoder(buf)
+	enc.SetIndent(2)
+	err = enc.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	return buf.Bytes(), err
+}
+
 // ErrExtraElementsInPath - Indicates when there is a final match and there are remaining path elements.
 var ErrExtraElementsInPath = fmt.Errorf("extra elements in path")
 
@@ -143,40 +207,65 @@ var ErrInvalidIndex = fmt.Errorf("invalid index")
 
 // NavigateTree allows you to define a path string to traverse a tree composed of maps and arrays.
 // To navigate through slices/arrays use a numerical index, for example: [path to array 1]
+// or a filter matching a single list item, for example: [path to array [name=api]]
+// Negative indexes count from the end of the list and 'start:end' slices return the list of items in the range.
+// Map keys that are integers, floats, booleans or null are matched by their string representation.
 // When include is true, the returned map will have the key as part of it.
+// When the path has wildcards, the returned element is the list of all the matches, see FindAll.
 func NavigateTree(include bool, m interface{}, p []string) (interface{}, []string, error) {
 	// Logger.Printf("type: %v, path: %v\n", reflect.TypeOf(m), p)
-	path := strings.Join(p, "/")
+	path := pathString(p)
 	Logger.Printf("NavigateTree: Self: %v, Input path: '%s'", include, path)
 	if len(p) <= 0 {
 		return m, p, nil
 	}
+	if HasWildcard(p) {
+		Logger.Printf("NavigateTree: wildcard path")
+		matches, err := FindAll(m, p)
+		if err != nil {
+			return m, p, err
+		}
+		values := []interface{}{}
+		for _, match := range matches {
+			values = append(values, match.Value)
+		}
+		return values, p[len(p):], nil
+	}
 	switch m.(type) {
 	case map[interface{}]interface{}:
 		Logger.Printf("NavigateTree: map type")
-		t, ok := m.(map[interface{}]interface{})[p[0]]
+		key, t, ok := mapKey(m.(map[interface{}]interface{}), p[0])
 		if !ok {
-			return m, p, fmt.Errorf("%w: %s", ErrMapKeyNotFound, p[0])
+			return m, p, fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(p[0]))
 		}
 		if include && len(p) == 1 {
 			Logger.Printf("NavigateTree: self return")
-			return map[interface{}]interface{}{p[0]: m.(map[interface{}]interface{})[p[0]]}, p[1:], nil
+			return map[interface{}]interface{}{key: t}, p[1:], nil
 		}
 		return NavigateTree(include, t, p[1:])
 	case []interface{}:
 		Logger.Printf("NavigateTree: slice/array type")
 
-		index, err := strconv.Atoi(p[0])
-		if err != nil {
-			return m, p, fmt.Errorf("%w: %s", ErrNotAnIndex, p[0])
+		if start, end, ok := parseSlice(p[0], len(m.([]interface{}))); ok {
+			Logger.Printf("NavigateTree: slice %d:%d", start, end)
+			values := []interface{}{}
+			for _, e := range m.([]interface{})[start:end] {
+				v, rp, err := NavigateTree(include, e, p[1:])
+				if err != nil {
+					return v, rp, err
+				}
+				values = append(values, v)
+			}
+			return values, p[len(p):], nil
 		}
-		if index < 0 || len(m.([]interface{})) <= index {
-			return m, p, fmt.Errorf("%w: %s", ErrInvalidIndex, p[0])
+		index, err := listIndex(m.([]interface{}), p[0])
+		if err != nil {
+			return m, p, err
 		}
 		return NavigateTree(include, m.([]interface{})[index], p[1:])
 	default:
 		Logger.Printf("NavigateTree: single element type")
-		return m, p, fmt.Errorf("%w: %s", ErrExtraElementsInPath, strings.Join(p, "/"))
+		return m, p, fmt.Errorf("%w: %s", ErrExtraElementsInPath, pathString(p))
 	}
 }
 
@@ -216,11 +305,10 @@ func AddChild(m *interface{}, child string) error {
 		Logger.Printf("AddChild: single element type")
 		return fmt.Errorf("%w", ErrInvalidParentType)
 	}
-	return nil
 }
 
 func AddChildToTree(parent *interface{}, current *interface{}, p []string, child string) error {
-	path := strings.Join(p, "/")
+	path := pathString(p)
 	Logger.Printf("AddChildToTree: Input path: '%s'", path)
 	if len(p) <= 0 {
 		Logger.Printf("Before %v, %v\n", *parent, *current)
@@ -234,24 +322,42 @@ func AddChildToTree(parent *interface{}, current *interface{}, p []string, child
 	switch t := (*current).(type) {
 	case map[interface{}]interface{}:
 		Logger.Printf("AddChildToTree: map type")
-		e, ok := t[p[0]]
+		key, e, ok := mapKey(t, p[0])
 		if !ok {
-			return fmt.Errorf("%w: %s", ErrMapKeyNotFound, p[0])
+			return fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(p[0]))
 		}
 		err := AddChildToTree(current, &e, p[1:], child)
 		if err != nil {
 			return err
 		}
-		(*current).(map[interface{}]interface{})[p[0]] = e
+		(*current).(map[interface{}]interface{})[key] = e
 		return nil
 	case []interface{}:
 		Logger.Printf("AddChildToTree: slice/array type")
-		index, err := strconv.Atoi(p[0])
-		if err != nil {
-			return fmt.Errorf("%w: %s", ErrNotAnIndex, p[0])
+		if start, end, ok := parseSlice(p[0], len(t)); ok {
+			Logger.Printf("AddChildToTree: slice %d:%d", start, end)
+			if len(p) == 1 {
+				var tree interface{}
+				err := yaml.Unmarshal([]byte(child), &tree)
+				if err != nil {
+					return err
+				}
+				r := append([]interface{}{}, t[:end]...)
+				r = append(r, tree)
+				*current = append(r, t[end:]...)
+				return nil
+			}
+			for i := start; i < end; i++ {
+				err := AddChildToTree(current, &t[i], p[1:], child)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
 		}
-		if index < 0 || len(t) <= index {
-			return fmt.Errorf("%w: %s", ErrInvalidIndex, p[0])
+		index, err := listIndex(t, p[0])
+		if err != nil {
+			return err
 		}
 		err = AddChildToTree(current, &t[index], p[1:], child)
 		if err != nil {
@@ -261,6 +367,175 @@ func AddChildToTree(parent *interface{}, current *interface{}, p []string, child
 		return nil
 	default:
 		Logger.Printf("AddChildToTree: single element type")
-		return fmt.Errorf("%w: %s", ErrExtraElementsInPath, strings.Join(p, "/"))
+		return fmt.Errorf("%w: %s", ErrExtraElementsInPath, pathString(p))
+	}
+}
+
+// ErrEmptyPath - The operation requires a path with at least one element.
+var ErrEmptyPath = fmt.Errorf("empty path")
+
+// Delete removes the map key or list item designated by path and returns the resulting document.
+// Path follows the same rules as GetString.
+func (y *YML) Delete(keys []string) (string, error) {
+	path := strings.Join(pathElements(keys), ",")
+	errPath := DeleteFromTree(&y.Tree, keys)
+	// Marshal complex response
+	out, err := y.marshalTree()
+	if errPath != nil {
+		if errors.Is(errPath, ErrEmptyPath) {
+			return string(out), errPath
+		}
+		return string(out), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
+	}
+	if err != nil {
+		return string(out), fmt.Errorf("failed to Marshal output: %w", err)
+	}
+	Logger.Printf("%s", out)
+	return string(out), nil
+}
+
+// DeleteFromTree navigates the tree following the given path and removes the last element of the path from its parent.
+// Map keys are deleted from the map and list items are removed from the list.
+func DeleteFromTree(current *interface{}, p []string) error {
+	path := pathString(p)
+	Logger.Printf("DeleteFromTree: Input path: '%s'", path)
+	if len(p) <= 0 {
+		return fmt.Errorf("%w", ErrEmptyPath)
+	}
+	switch t := (*current).(type) {
+	case map[interface{}]interface{}:
+		Logger.Printf("DeleteFromTree: map type")
+		key, e, ok := mapKey(t, p[0])
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(p[0]))
+		}
+		if len(p) == 1 {
+			delete(t, key)
+			return nil
+		}
+		err := DeleteFromTree(&e, p[1:])
+		if err != nil {
+			return err
+		}
+		t[key] = e
+		return nil
+	case []interface{}:
+		Logger.Printf("DeleteFromTree: slice/array type")
+		if start, end, ok := parseSlice(p[0], len(t)); ok {
+			Logger.Printf("DeleteFromTree: slice %d:%d", start, end)
+			if len(p) == 1 {
+				r := append([]interface{}{}, t[:start]...)
+				*current = append(r, t[end:]...)
+				return nil
+			}
+			for i := start; i < end; i++ {
+				err := DeleteFromTree(&t[i], p[1:])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		index, err := listIndex(t, p[0])
+		if err != nil {
+			return err
+		}
+		if len(p) == 1 {
+			r := append([]interface{}{}, t[:index]...)
+			*current = append(r, t[index+1:]...)
+			return nil
+		}
+		return DeleteFromTree(&t[index], p[1:])
+	default:
+		Logger.Printf("DeleteFromTree: single element type")
+		return fmt.Errorf("%w: %s", ErrExtraElementsInPath, pathString(p))
+	}
+}
+
+// Set replaces the element designated by path with the yaml/json input string and returns the resulting document.
+// Missing map keys in the last element of the path are created.
+// When createParents is true, missing intermediate map keys are created as maps.
+func (y *YML) Set(keys []string, input string, createParents bool) (string, error) {
+	path := strings.Join(pathElements(keys), ",")
+	var child interface{}
+	errPath := yaml.Unmarshal([]byte(input), &child)
+	if errPath == nil {
+		errPath = SetChildInTree(&y.Tree, keys, child, createParents)
+	}
+	// Marshal complex response
+	out, err := y.marshalTree()
+	if errPath != nil {
+		if errors.Is(errPath, ErrInvalidParentType) {
+			return string(out), fmt.Errorf("yaml path '%s': %w", path, errPath)
+		}
+		return string(out), fmt.Errorf("yaml path '%s' didn't return a valid string: %w", path, errPath)
+	}
+	if err != nil {
+		return string(out), fmt.Errorf("failed to Marshal output: %w", err)
+	}
+	Logger.Printf("%s", out)
+	return string(out), nil
+}
+
+// SetChildInTree navigates the tree following the given path and replaces the last element of the path with child.
+// When createParents is true, missing or null intermediate elements are created as maps.
+func SetChildInTree(current *interface{}, p []string, child interface{}, createParents bool) error {
+	path := pathString(p)
+	Logger.Printf("SetChildInTree: Input path: '%s'", path)
+	if len(p) <= 0 {
+		*current = child
+		return nil
+	}
+	if *current == nil && createParents {
+		Logger.Printf("SetChildInTree: creating parent")
+		*current = map[interface{}]interface{}{}
+	}
+	switch t := (*current).(type) {
+	case map[interface{}]interface{}:
+		Logger.Printf("SetChildInTree: map type")
+		key, e, ok := mapKey(t, p[0])
+		// Wildcards, filters and slices are only created as keys when they are escaped or quoted
+		if !ok && isPattern(p[0]) {
+			return fmt.Errorf("%w: %s, escape or quote it to create the map key", ErrNoMatch, p[0])
+		}
+		if !ok && len(p) > 1 && !createParents {
+			return fmt.Errorf("%w: %s", ErrMapKeyNotFound, pathElement(p[0]))
+		}
+		err := SetChildInTree(&e, p[1:], child, createParents)
+		if err != nil {
+			return err
+		}
+		t[key] = e
+		return nil
+	case []interface{}:
+		Logger.Printf("SetChildInTree: slice/array type")
+		if start, end, ok := parseSlice(p[0], len(t)); ok {
+			Logger.Printf("SetChildInTree: slice %d:%d", start, end)
+			if len(p) == 1 {
+				items, isList := child.([]interface{})
+				if !isList {
+					items = []interface{}{child}
+				}
+				r := append([]interface{}{}, t[:start]...)
+				r = append(r, items...)
+				*current = append(r, t[end:]...)
+				return nil
+			}
+			for i := start; i < end; i++ {
+				err := SetChildInTree(&t[i], p[1:], child, createParents)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		index, err := listIndex(t, p[0])
+		if err != nil {
+			return err
+		}
+		return SetChildInTree(&t[index], p[1:], child, createParents)
+	default:
+		Logger.Printf("SetChildInTree: single element type")
+		return fmt.Errorf("%w: %s", ErrInvalidParentType, pathString(p))
 	}
 }
@@ -35,10 +35,30 @@ func TestGetString(t *testing.T) {
   - one
   - two
   - three`, "- one\n- two\n- three\n", ErrInvalidIndex},
-		{"simple", false, []string{"hello", "-1"}, `hello:
+		{"negative index", false, []string{"hello", "-1"}, `hello:
+  - one
+  - two
+  - three`, "three", nil},
+		{"negative index", false, []string{"hello", "-4"}, `hello:
   - one
   - two
   - three`, "- one\n- two\n- three\n", ErrInvalidIndex},
+		{"slice", false, []string{"hello", "1:"}, `hello:
+  - one
+  - two
+  - three`, "- two\n- three\n", nil},
+		{"slice", false, []string{"hello", ":-1"}, `hello:
+  - one
+  - two
+  - three`, "- one\n- two\n", nil},
+		{"slice empty", false, []string{"hello", "2:1"}, `hello:
+  - one
+  - two
+  - three`, "[]\n", nil},
+		{"slice path", false, []string{"hello", "0:2", "name"}, `hello:
+  - name: one
+  - name: two
+  - name: three`, "- one\n- two\n", nil},
 		{"simple", false, []string{"hello", "1", "world"}, `hello:
   - one
   - world: hola
@@ -59,6 +79,21 @@ func TestGetString(t *testing.T) {
   - one
   - world: 123.123
   - three`, "world: 123.123\n", nil},
+		{"filter", false, []string{"hello", "[name=b]", "port"}, `hello:
+  - name: a
+    port: 80
+  - name: b
+    port: 81`, "81", nil},
+		{"filter", false, []string{"hello", "[port>80]", "name"}, `hello:
+  - name: a
+    port: 80
+  - name: b
+    port: 81`, "b", nil},
+		{"filter multiple matches", false, []string{"hello", "[port>=80]", "name"}, `hello:
+  - name: a
+    port: 80
+  - name: b
+    port: 81`, "- name: a\n  port: 80\n- name: b\n  port: 81\n", ErrMultipleMatches},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -197,6 +232,32 @@ func TestAddChildToTree(t *testing.T) {
 		{"map", []string{}, map[interface{}]interface{}{"map": "hola"}, map[interface{}]interface{}{"map": "hola", "hello": "world"}, "hello: world", nil},
 		{"map", []string{"map"}, map[interface{}]interface{}{"map": []interface{}{"one", "two", "three"}},
 			map[interface{}]interface{}{"map": []interface{}{"one", "two", "three", "four"}}, "four", nil},
+		{"filter", []string{"[name=b]", "tags"},
+			[]interface{}{
+				map[interface{}]interface{}{"name": "a", "tags": []interface{}{}},
+				map[interface{}]interface{}{"name": "b", "tags": []interface{}{"x"}},
+			},
+			[]interface{}{
+				map[interface{}]interface{}{"name": "a", "tags": []interface{}{}},
+				map[interface{}]interface{}{"name": "b", "tags": []interface{}{"x", "z"}},
+			}, "z", nil},
+		{"negative index", []string{"-1"}, []interface{}{[]interface{}{"a"}, []interface{}{"b"}},
+			[]interface{}{[]interface{}{"a"}, []interface{}{"b", "z"}}, "z", nil},
+		{"slice insert", []string{"1:1"}, []interface{}{"a", "b"}, []interface{}{"a", "z", "b"}, "z", nil},
+		{"slice prepend", []string{":0"}, []interface{}{"a", "b"}, []interface{}{"z", "a", "b"}, "z", nil},
+		{"slice each", []string{"0:2", "l"},
+			[]interface{}{
+				map[interface{}]interface{}{"l": []interface{}{"a"}},
+				map[interface{}]interface{}{"l": []interface{}{"b"}},
+				map[interface{}]interface{}{"l": []interface{}{"c"}},
+			},
+			[]interface{}{
+				map[interface{}]interface{}{"l": []interface{}{"a", "z"}},
+				map[interface{}]interface{}{"l": []interface{}{"b", "z"}},
+				map[interface{}]interface{}{"l": []interface{}{"c"}},
+			}, "z", nil},
+		{"filter no match", []string{"[name=c]"}, []interface{}{map[interface{}]interface{}{"name": "a"}},
+			[]interface{}{map[interface{}]interface{}{"name": "a"}}, "z", ErrNoMatch},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -214,3 +275,106 @@ func TestAddChildToTree(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteFromTree(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      []string
+		structure interface{}
+		expected  interface{}
+		err       error
+	}{
+		{"empty path", []string{}, "hola", "hola", ErrEmptyPath},
+		{"simple", []string{"hola"}, "hola", "hola", ErrExtraElementsInPath},
+		{"map", []string{"map"}, map[interface{}]interface{}{"map": "hola", "hello": "world"}, map[interface{}]interface{}{"hello": "world"}, nil},
+		{"map missing key", []string{"x"}, map[interface{}]interface{}{"map": "hola"}, map[interface{}]interface{}{"map": "hola"}, ErrMapKeyNotFound},
+		{"array", []string{"1"}, []interface{}{"one", "two", "three"}, []interface{}{"one", "three"}, nil},
+		{"array last", []string{"2"}, []interface{}{"one", "two", "three"}, []interface{}{"one", "two"}, nil},
+		{"array not an index", []string{"x"}, []interface{}{"one"}, []interface{}{"one"}, ErrNotAnIndex},
+		{"array invalid index", []string{"1"}, []interface{}{"one"}, []interface{}{"one"}, ErrInvalidIndex},
+		{"array negative index", []string{"-1"}, []interface{}{"one", "two"}, []interface{}{"one"}, nil},
+		{"array slice", []string{"1:-1"}, []interface{}{"one", "two", "three", "four"}, []interface{}{"one", "four"}, nil},
+		{"array slice each", []string{":", "a"},
+			[]interface{}{map[interface{}]interface{}{"a": 1, "b": 2}, map[interface{}]interface{}{"a": 1}},
+			[]interface{}{map[interface{}]interface{}{"b": 2}, map[interface{}]interface{}{}}, nil},
+		{"map in array", []string{"map", "0"}, map[interface{}]interface{}{"map": []interface{}{"one", "two", "three"}},
+			map[interface{}]interface{}{"map": []interface{}{"two", "three"}}, nil},
+		{"array in map", []string{"0", "hello"}, []interface{}{map[interface{}]interface{}{"hello": "world", "hola": "mundo"}},
+			[]interface{}{map[interface{}]interface{}{"hola": "mundo"}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			err := DeleteFromTree(&test.structure, test.path)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(test.structure, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, test.structure)
+			}
+			t.Log(buf.String())
+		})
+	}
+}
+
+func TestSetChildInTree(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          []string
+		createParents bool
+		structure     interface{}
+		child         interface{}
+		expected      interface{}
+		err           error
+	}{
+		{"root", []string{}, false, "hola", "hello", "hello", nil},
+		{"scalar parent", []string{"x"}, false, "hola", "hello", "hola", ErrInvalidParentType},
+		{"scalar parent", []string{"x"}, true, "hola", "hello", "hola", ErrInvalidParentType},
+		{"map replace", []string{"map"}, false, map[interface{}]interface{}{"map": "hola"}, "hello", map[interface{}]interface{}{"map": "hello"}, nil},
+		{"map new key", []string{"new"}, false, map[interface{}]interface{}{"map": "hola"}, "hello",
+			map[interface{}]interface{}{"map": "hola", "new": "hello"}, nil},
+		{"map missing parent", []string{"a", "b"}, false, map[interface{}]interface{}{"map": "hola"}, "hello",
+			map[interface{}]interface{}{"map": "hola"}, ErrMapKeyNotFound},
+		{"map create parents", []string{"a", "b", "c"}, true, map[interface{}]interface{}{"map": "hola"}, 3,
+			map[interface{}]interface{}{"map": "hola", "a": map[interface{}]interface{}{"b": map[interface{}]interface{}{"c": 3}}}, nil},
+		{"null parent", []string{"map", "b"}, true, map[interface{}]interface{}{"map": nil}, true,
+			map[interface{}]interface{}{"map": map[interface{}]interface{}{"b": true}}, nil},
+		{"array replace", []string{"map", "1"}, false, map[interface{}]interface{}{"map": []interface{}{"one", "two", "three"}}, "dos",
+			map[interface{}]interface{}{"map": []interface{}{"one", "dos", "three"}}, nil},
+		{"array replace complex", []string{"1"}, false, []interface{}{"one", "two"}, map[interface{}]interface{}{"hello": "world"},
+			[]interface{}{"one", map[interface{}]interface{}{"hello": "world"}}, nil},
+		{"array invalid index", []string{"2"}, true, []interface{}{"one", "two"}, "three", []interface{}{"one", "two"}, ErrInvalidIndex},
+		{"array negative index", []string{"-2"}, false, []interface{}{"one", "two"}, "uno", []interface{}{"uno", "two"}, nil},
+		{"array slice", []string{"0:2"}, false, []interface{}{"one", "two", "three"}, "uno", []interface{}{"uno", "three"}, nil},
+		{"array slice list", []string{"1:"}, false, []interface{}{"one", "two", "three"}, []interface{}{"dos", "tres", "cuatro"},
+			[]interface{}{"one", "dos", "tres", "cuatro"}, nil},
+		{"array not an index", []string{"x"}, true, []interface{}{"one", "two"}, "three", []interface{}{"one", "two"}, ErrNotAnIndex},
+		{"map wildcard", []string{"*"}, false, map[interface{}]interface{}{"map": "hola"}, "hello",
+			map[interface{}]interface{}{"map": "hola"}, ErrNoMatch},
+		{"map filter", []string{"[a=b]", "c"}, true, map[interface{}]interface{}{"map": "hola"}, "hello",
+			map[interface{}]interface{}{"map": "hola"}, ErrNoMatch},
+		{"map slice", []string{"1:2"}, false, map[interface{}]interface{}{"map": "hola"}, "hello",
+			map[interface{}]interface{}{"map": "hola"}, ErrNoMatch},
+		{"map literal wildcard", []string{LiteralKey("*")}, false, map[interface{}]interface{}{"map": "hola"}, "hello",
+			map[interface{}]interface{}{"map": "hola", "*": "hello"}, nil},
+		{"map existing slice key", []string{"1:2"}, false, map[interface{}]interface{}{"1:2": "hola"}, "hello",
+			map[interface{}]interface{}{"1:2": "hello"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := ""
+			buf := bytes.NewBufferString(s)
+			Logger.SetOutput(buf)
+			err := SetChildInTree(&test.structure, test.path, test.child, test.createParents)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Unexpected error: %s\n", err)
+			}
+			if !reflect.DeepEqual(test.structure, test.expected) {
+				t.Errorf("Expected:\n%#v\nGot:\n%#v\n", test.expected, test.structure)
+			}
+			t.Log(buf.String())
+		})
+	}
+}